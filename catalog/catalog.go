@@ -0,0 +1,137 @@
+// Package catalog holds the canonical names of tradeable items and matches free-typed names against them.
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//go:embed items.json
+var bundled []byte
+
+// Entry is an item under its canonical name, with the other names members use for it
+type Entry struct {
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Aliases  []string `json:"aliases"`
+}
+
+// Match is a candidate name scored against a query
+type Match struct {
+	Name  string
+	Score float64 // 1 for an exact match, 0 for nothing in common
+}
+
+// Bundled returns the entries of the catalog shipped with the bot
+func Bundled() ([]Entry, error) {
+	var entries []Entry
+	if err := json.Unmarshal(bundled, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Normalize folds a name for comparison: lowercase, apostrophes dropped and
+// punctuation and repeated spaces collapsed, so "Gemcutter's  prism" equals "gemcutters prism"
+func Normalize(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case r == '\'' || r == '’':
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// Similarity scores how alike two names are, from 0 to 1.
+// It is the Dice coefficient of their trigrams, raised for names that
+// start with or contain the other, so short typed prefixes rank well.
+func Similarity(a, b string) float64 {
+	a, b = Normalize(a), Normalize(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	score := dice(trigrams(a), trigrams(b))
+
+	// Typing the start of a name is the most common way to look it up
+	shorter, longer := a, b
+	if len(shorter) > len(longer) {
+		shorter, longer = longer, shorter
+	}
+	coverage := float64(len(shorter)) / float64(len(longer))
+	switch {
+	case strings.HasPrefix(longer, shorter):
+		score = max(score, 0.6+0.35*coverage)
+	case strings.Contains(longer, shorter):
+		score = max(score, 0.5+0.35*coverage)
+	}
+
+	return score
+}
+
+// Score returns how well a query matches the entry's name or the best of its aliases
+func (e Entry) Score(query string) float64 {
+	score := Similarity(query, e.Name)
+	for _, alias := range e.Aliases {
+		score = max(score, Similarity(query, alias))
+	}
+	return score
+}
+
+// Rank scores every entry against the query and returns the names of those scoring
+// at least minScore, best first. Entries with equal scores keep their order.
+func Rank(query string, entries []Entry, minScore float64) []Match {
+	var matches []Match
+	for _, entry := range entries {
+		if score := entry.Score(query); score >= minScore {
+			matches = append(matches, Match{Name: entry.Name, Score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].Score > matches[b].Score
+	})
+	return matches
+}
+
+// trigrams returns the set of three-letter sequences of a name, padded so short names have some
+func trigrams(s string) map[string]bool {
+	padded := []rune("  " + s + " ")
+	set := make(map[string]bool, len(padded))
+	for idx := 0; idx+3 <= len(padded); idx++ {
+		set[string(padded[idx:idx+3])] = true
+	}
+	return set
+}
+
+// dice returns the Dice coefficient of two trigram sets
+func dice(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for gram := range a {
+		if b[gram] {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
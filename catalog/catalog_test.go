@@ -0,0 +1,83 @@
+package catalog
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Divine Orb", "divine orb"},
+		{"  Gemcutter's  prism ", "gemcutters prism"},
+		{"Kaom’s Heart", "kaoms heart"},
+		{"Orb-of-Alchemy!", "orb of alchemy"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.name); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		atLeast float64
+		below   float64
+	}{
+		{a: "divine orb", b: "Divine Orb", atLeast: 1, below: 1.1},
+		{a: "divine", b: "Divine Orb", atLeast: 0.8, below: 1},
+		{a: "divne orb", b: "Divine Orb", atLeast: 0.5, below: 1},
+		{a: "orb", b: "Divine Orb", atLeast: 0.5, below: 0.8},
+		{a: "mirror", b: "Divine Orb", atLeast: 0, below: 0.2},
+		{a: "", b: "Divine Orb", atLeast: 0, below: 1e-9},
+	}
+
+	for _, tt := range tests {
+		got := Similarity(tt.a, tt.b)
+		if got < tt.atLeast || got >= tt.below {
+			t.Errorf("Similarity(%q, %q) = %v, want it in [%v, %v)", tt.a, tt.b, got, tt.atLeast, tt.below)
+		}
+		if reverse := Similarity(tt.b, tt.a); reverse != got {
+			t.Errorf("Similarity(%q, %q) = %v, but %v the other way around", tt.a, tt.b, got, reverse)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	entries, err := Bundled()
+	if err != nil {
+		t.Fatalf("Bundled() error = %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"divine", "Divine Orb"},
+		{"Divine orb", "Divine Orb"},
+		{"divs", "Divine Orb"},
+		{"divne", "Divine Orb"},
+		{"exalted", "Exalted Orb"},
+		{"greater ex", "Greater Exalted Orb"},
+		{"gemcutters", "Gemcutter's Prism"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			matches := Rank(tt.query, entries, 0.3)
+			if len(matches) == 0 {
+				t.Fatalf("Rank(%q) found nothing, want %q", tt.query, tt.want)
+			}
+			if matches[0].Name != tt.want {
+				t.Errorf("Rank(%q) best match = %q, want %q (all: %v)", tt.query, matches[0].Name, tt.want, matches)
+			}
+		})
+	}
+
+	if matches := Rank("zzzz", entries, 0.3); len(matches) != 0 {
+		t.Errorf("Rank(%q) = %v, want no matches", "zzzz", matches)
+	}
+}
@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/db"
+)
+
+// auditTimelineLimit is how many entries of an item's timeline fit in one embed
+const auditTimelineLimit = 24
+
+// auditEntry is one line of an item's timeline: a change, or a loot admin override
+type auditEntry struct {
+	at    time.Time
+	name  string
+	value string
+}
+
+// actorOf returns the member behind an interaction, for the audit log
+func actorOf(i *discordgo.InteractionCreate) db.Actor {
+	return db.Actor{UserID: i.Member.User.ID, InteractionID: i.ID}
+}
+
+// handleSlashAudit handles the /docteur audit command by showing every change made to an item
+func handleSlashAudit(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Audit] Processing audit request from user %s", i.Member.User.Username)
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	itemID, err := strconv.ParseInt(optionMap["item"].StringValue(), 10, 64)
+	if err != nil {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Invalid item ID. Please provide a valid number.",
+			},
+		})
+		return
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	item, err := db.GetItem(i.GuildID, itemID)
+	if err != nil {
+		log.Printf("Error getting item: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get item: " + err.Error()),
+		})
+		return
+	}
+
+	events, err := db.GetItemAuditTrail(i.GuildID, itemID)
+	if err != nil {
+		log.Printf("[Audit] Failed to get audit trail of item #%d: %v", itemID, err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get audit trail: " + err.Error()),
+		})
+		return
+	}
+
+	overrides, err := db.GetItemOverrides(i.GuildID, itemID)
+	if err != nil {
+		log.Printf("[Audit] Failed to get overrides of item #%d: %v", itemID, err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get admin overrides: " + err.Error()),
+		})
+		return
+	}
+
+	// Changes and the overrides that allowed them make up one timeline
+	var timeline []auditEntry
+	for _, event := range events {
+		timeline = append(timeline, auditEntry{
+			at:   event.CreatedAt,
+			name: fmt.Sprintf("#%d %s · %s", event.ID, event.Action, event.CreatedAt.Format("Jan 02, 2006 15:04")),
+			value: truncateField(fmt.Sprintf("by <@%s>\n%s\ninteraction `%s`",
+				event.ActorID, formatAuditChanges(event.Before, event.After), event.InteractionID)),
+		})
+	}
+	for _, override := range overrides {
+		timeline = append(timeline, auditEntry{
+			at:    override.CreatedAt,
+			name:  fmt.Sprintf("🛡️ loot admin override: %s · %s", override.Action, override.CreatedAt.Format("Jan 02, 2006 15:04")),
+			value: truncateField(fmt.Sprintf("by <@%s>: %s", override.UserID, override.Detail)),
+		})
+	}
+	sort.SliceStable(timeline, func(a, b int) bool {
+		return timeline[a].at.Before(timeline[b].at)
+	})
+
+	description := fmt.Sprintf("%d changes, oldest first.", len(events))
+	if len(timeline) > auditTimelineLimit {
+		description = fmt.Sprintf("%d changes, showing the latest %d, oldest first.", len(events), auditTimelineLimit)
+		timeline = timeline[len(timeline)-auditTimelineLimit:]
+	}
+	if len(timeline) == 0 {
+		description = "No changes were recorded for this item. It was logged before the audit log existed."
+	}
+
+	var fields []*discordgo.MessageEmbedField
+	for _, entry := range timeline {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   entry.name,
+			Value:  entry.value,
+			Inline: false,
+		})
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🔍 Audit Trail: %s (ID: %d)", item.Name, item.ID),
+		Description: description,
+		Color:       0x00ffff,
+		Fields:      fields,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Audit] Successfully displayed %d audit events of item #%d", len(events), itemID)
+}
+
+// formatAuditChanges describes the difference between the JSON states before and after a change
+func formatAuditChanges(before string, after string) string {
+	var oldState, newState map[string]any
+	if before != "" {
+		if err := json.Unmarshal([]byte(before), &oldState); err != nil {
+			return "before: " + before
+		}
+	}
+	if after != "" {
+		if err := json.Unmarshal([]byte(after), &newState); err != nil {
+			return "after: " + after
+		}
+	}
+
+	keys := make(map[string]bool)
+	for key := range oldState {
+		keys[key] = true
+	}
+	for key := range newState {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var lines []string
+	for _, key := range sorted {
+		oldValue, hadOld := oldState[key]
+		newValue, hasNew := newState[key]
+		switch {
+		case oldState == nil:
+			lines = append(lines, fmt.Sprintf("%s: %s", key, formatAuditValue(key, newValue)))
+		case newState == nil:
+			lines = append(lines, fmt.Sprintf("%s: ~~%s~~", key, formatAuditValue(key, oldValue)))
+		case !hadOld:
+			lines = append(lines, fmt.Sprintf("%s: **%s**", key, formatAuditValue(key, newValue)))
+		case !hasNew:
+			lines = append(lines, fmt.Sprintf("%s: ~~%s~~", key, formatAuditValue(key, oldValue)))
+		default:
+			oldText, newText := formatAuditValue(key, oldValue), formatAuditValue(key, newValue)
+			if oldText != newText {
+				lines = append(lines, fmt.Sprintf("%s: ~~%s~~ → **%s**", key, oldText, newText))
+			}
+		}
+	}
+
+	switch {
+	case oldState == nil && newState == nil:
+		return "Nothing recorded"
+	case newState == nil:
+		return "Removed\n" + strings.Join(lines, "\n")
+	case len(lines) == 0:
+		return "No visible change"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatAuditValue renders a value of an audited state; members are mentioned and sales summarized
+func formatAuditValue(key string, value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "none"
+	case string:
+		if v == "" {
+			return "none"
+		}
+		switch key {
+		case "seller", "recipient":
+			return fmt.Sprintf("<@%s>", v)
+		case "role":
+			return fmt.Sprintf("<@&%s>", v)
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]any:
+		// Participants map user IDs to share weights
+		if key == "participants" {
+			userIDs := make([]string, 0, len(v))
+			for userID := range v {
+				userIDs = append(userIDs, userID)
+			}
+			sort.Strings(userIDs)
+
+			var mentions []string
+			for _, userID := range userIDs {
+				weight, _ := v[userID].(float64)
+				mentions = append(mentions, fmt.Sprintf("<@%s>%s", userID, formatWeight(weight)))
+			}
+			return strings.Join(mentions, ", ")
+		}
+	case []any:
+		if key == "sales" {
+			var sales []string
+			for _, entry := range v {
+				sale, ok := entry.(map[string]any)
+				if !ok {
+					continue
+				}
+				line := fmt.Sprintf("#%s: %s × for %s %s", formatAuditValue("", sale["id"]), formatAuditValue("", sale["quantity"]),
+					formatAuditValue("", sale["original_amount"]), formatAuditValue("", sale["currency"]))
+				if sale["status"] == db.SaleReversed {
+					line += " (reversed)"
+				}
+				sales = append(sales, line)
+			}
+			return strings.Join(sales, ", ")
+		}
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// truncateField keeps a value within the 1024 characters of an embed field
+func truncateField(value string) string {
+	if len(value) <= 1000 {
+		return value
+	}
+
+	// Cut on a rune boundary so mentions and emojis aren't split mid-character
+	cut := 1000
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut] + "\n…"
+}
@@ -0,0 +1,287 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/currency"
+	"github.com/intinig/dr-peste/db"
+)
+
+// bankHistoryLimit is how many ledger entries /docteur bank history shows
+const bankHistoryLimit = 15
+
+// handleSlashBank handles the /docteur bank command group
+func handleSlashBank(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(data.Options) == 0 {
+		return
+	}
+
+	subCommand := data.Options[0]
+	switch subCommand.Name {
+	case "deposit":
+		handleSlashBankDeposit(s, i, subCommand)
+	case "withdraw":
+		handleSlashBankWithdraw(s, i, subCommand)
+	case "balance":
+		handleSlashBankBalance(s, i)
+	case "history":
+		handleSlashBankHistory(s, i)
+	}
+}
+
+// handleSlashBankDeposit handles the /docteur bank deposit command
+func handleSlashBankDeposit(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Bank] Processing deposit from user %s", i.Member.User.Username)
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	amount := optionMap["amount"].IntValue()
+	if amount <= 0 {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ The amount must be greater than zero.",
+			},
+		})
+		return
+	}
+
+	var note string
+	if noteOpt, ok := optionMap["note"]; ok {
+		note = strings.TrimSpace(noteOpt.StringValue())
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	if err := db.DepositToBank(i.GuildID, amount, note, actorOf(i)); err != nil {
+		log.Printf("[Bank] Failed to deposit: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to record deposit: " + err.Error()),
+		})
+		return
+	}
+
+	embed := bankMovementEmbed(i.GuildID, "Deposit Recorded",
+		fmt.Sprintf("%s donated %s to the guild bank", i.Member.User.Mention(), currency.FormatBase(amount)), note, 0x00ff00)
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Bank] Recorded deposit of %s by %s", currency.FormatBase(amount), i.Member.User.Username)
+}
+
+// handleSlashBankWithdraw handles the /docteur bank withdraw command
+func handleSlashBankWithdraw(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Bank] Processing withdrawal from user %s", i.Member.User.Username)
+
+	// Only admins can spend the guild's orbs
+	if !isLootAdmin(i) {
+		log.Printf("[Bank] Rejected: User %s is not a loot admin", i.Member.User.Username)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Only loot admins can withdraw from the guild bank.",
+			},
+		})
+		return
+	}
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	amount := optionMap["amount"].IntValue()
+	if amount <= 0 {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ The amount must be greater than zero.",
+			},
+		})
+		return
+	}
+
+	reason := strings.TrimSpace(optionMap["reason"].StringValue())
+
+	// The orbs go to the admin unless someone else is named
+	recipient := i.Member.User
+	if recipientOpt, ok := optionMap["recipient"]; ok {
+		recipient = recipientOpt.UserValue(nil)
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	if err := db.WithdrawFromBank(i.GuildID, recipient.ID, amount, reason, actorOf(i)); err != nil {
+		log.Printf("[Bank] Failed to withdraw: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to record withdrawal: " + err.Error()),
+		})
+		return
+	}
+
+	embed := bankMovementEmbed(i.GuildID, "Withdrawal Recorded",
+		fmt.Sprintf("%s paid %s from the guild bank to %s", i.Member.User.Mention(), currency.FormatBase(amount), recipient.Mention()), reason, 0xffa500)
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Bank] Recorded withdrawal of %s to %s authorized by %s", currency.FormatBase(amount), recipient.Username, i.Member.User.Username)
+}
+
+// handleSlashBankBalance handles the /docteur bank balance command
+func handleSlashBankBalance(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	log.Printf("[Bank] Processing balance request from user %s", i.Member.User.Username)
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	balance, err := db.GetBankBalance(i.GuildID)
+	if err != nil {
+		log.Printf("[Bank] Failed to get balance: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get bank balance: " + err.Error()),
+		})
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "🏦 Guild Bank",
+		Description: fmt.Sprintf("The guild bank holds **%s**", currency.FormatBase(balance)),
+		Color:       0x00ffff,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+}
+
+// handleSlashBankHistory handles the /docteur bank history command
+func handleSlashBankHistory(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	log.Printf("[Bank] Processing history request from user %s", i.Member.User.Username)
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	entries, err := db.GetBankHistory(i.GuildID, bankHistoryLimit)
+	if err != nil {
+		log.Printf("[Bank] Failed to get history: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get bank history: " + err.Error()),
+		})
+		return
+	}
+
+	if len(entries) == 0 {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("The guild bank has no history yet."),
+		})
+		return
+	}
+
+	var history strings.Builder
+	for _, entry := range entries {
+		history.WriteString(formatBankEntry(entry) + "\n")
+	}
+
+	balance, err := db.GetBankBalance(i.GuildID)
+	if err != nil {
+		log.Printf("[Bank] Failed to get balance: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get bank balance: " + err.Error()),
+		})
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "🏦 Guild Bank History",
+		Description: fmt.Sprintf("Balance: **%s**", currency.FormatBase(balance)),
+		Color:       0x00ffff,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   fmt.Sprintf("Last %d Movements", len(entries)),
+				Value:  history.String(),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+}
+
+// bankMovementEmbed builds the response to a deposit or withdrawal, including the new balance
+func bankMovementEmbed(guildID string, title string, description string, note string, color int) *discordgo.MessageEmbed {
+	var fields []*discordgo.MessageEmbedField
+	if note != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Note",
+			Value:  note,
+			Inline: false,
+		})
+	}
+
+	balance, err := db.GetBankBalance(guildID)
+	if err != nil {
+		log.Printf("[Bank] Failed to get balance: %v", err)
+	} else {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "New Balance",
+			Value:  currency.FormatBase(balance),
+			Inline: true,
+		})
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description,
+		Color:       color,
+		Fields:      fields,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+}
+
+// formatBankEntry renders a bank ledger entry for the history
+func formatBankEntry(entry db.BankEntry) string {
+	var line string
+	switch entry.Kind {
+	case db.BankTax:
+		if entry.Amount < 0 {
+			line = fmt.Sprintf("↩️ -%s tax returned to <@%s> for item #%d", currency.FormatBase(-entry.Amount), entry.UserID, entry.ItemID)
+		} else {
+			line = fmt.Sprintf("🧾 +%s tax from <@%s> for item #%d", currency.FormatBase(entry.Amount), entry.UserID, entry.ItemID)
+		}
+	case db.BankDeposit:
+		line = fmt.Sprintf("💰 +%s donated by <@%s>", currency.FormatBase(entry.Amount), entry.UserID)
+	case db.BankWithdrawal:
+		line = fmt.Sprintf("💸 -%s to <@%s>, authorized by <@%s>", currency.FormatBase(-entry.Amount), entry.UserID, entry.AuthorizedBy)
+	}
+
+	line += " on " + entry.CreatedAt.Format("Jan 02")
+	if entry.Note != "" && entry.Kind != db.BankTax {
+		line += ": " + entry.Note
+	}
+	return line
+}
@@ -0,0 +1,339 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/catalog"
+	"github.com/intinig/dr-peste/db"
+)
+
+// Limits of item name matching
+const (
+	autocompleteScore    = 0.3 // lowest similarity of a name offered while typing
+	unmatchedNamesListed = 20  // unmatched names listed by /docteur admin unmatched
+	mergedItemsListed    = 20  // affected items listed by /docteur admin merge-names
+)
+
+// resolveItemName maps a typed item name to its catalog name. Names that aren't in the
+// catalog are kept as typed and, if flag is set, recorded for an admin to merge.
+func resolveItemName(guildID string, name string, flag bool) *db.NameMatch {
+	match, err := db.ResolveItemName(guildID, name)
+	if err != nil {
+		log.Printf("[Catalog] Warning: Failed to resolve item name %q: %v", name, err)
+		return &db.NameMatch{Name: strings.TrimSpace(name)}
+	}
+
+	if flag && !match.Matched {
+		if err := db.FlagUnmatchedName(guildID, match.Name, match.Suggestion); err != nil {
+			log.Printf("[Catalog] Warning: Failed to flag unmatched name %q: %v", match.Name, err)
+		} else {
+			log.Printf("[Catalog] Flagged unmatched name %q (suggested %q)", match.Name, match.Suggestion)
+		}
+	}
+
+	return match
+}
+
+// formatUnmatchedName explains that a name isn't in the catalog, with the closest catalog name if there is one
+func formatUnmatchedName(match *db.NameMatch) string {
+	text := fmt.Sprintf("**%s** isn't in the item catalog, so an admin will be asked to merge it.", match.Name)
+	if match.Suggestion != "" {
+		text += fmt.Sprintf(" Did you mean **%s**?", match.Suggestion)
+	}
+	return text
+}
+
+// handleItemNameAutocomplete suggests item names when adding, renaming or pricing items.
+// Catalog names and their aliases are ranked by how closely they match what was typed,
+// along with names the guild used before that aren't in the catalog.
+func handleItemNameAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, query string) {
+	entries, err := db.ListCatalog()
+	if err != nil {
+		log.Printf("Error listing catalog for name autocomplete: %v", err)
+		return
+	}
+
+	items, err := db.ListItems(i.GuildID)
+	if err != nil {
+		log.Printf("Error listing items for name autocomplete: %v", err)
+		return
+	}
+
+	known := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		known[strings.ToLower(entry.Name)] = true
+	}
+
+	// Items are listed newest first, so recently used names come first
+	var used []catalog.Entry
+	for _, item := range items {
+		if !known[strings.ToLower(item.Name)] {
+			known[strings.ToLower(item.Name)] = true
+			used = append(used, catalog.Entry{Name: item.Name})
+		}
+	}
+
+	// Until something is typed, offer the guild's own names and then the catalog
+	var matches []catalog.Match
+	if strings.TrimSpace(query) == "" {
+		for _, entry := range append(used, entries...) {
+			matches = append(matches, catalog.Match{Name: entry.Name})
+		}
+	} else {
+		matches = catalog.Rank(query, append(entries, used...), autocompleteScore)
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, match := range matches {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  match.Name,
+			Value: match.Name,
+		})
+
+		// Limit to 25 choices (Discord's maximum)
+		if len(choices) >= 25 {
+			break
+		}
+	}
+
+	// Respond with the choices
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+
+	if err != nil {
+		log.Printf("Error responding to name autocomplete: %v", err)
+	}
+}
+
+// handleSlashAdmin handles the /docteur admin command group
+func handleSlashAdmin(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(data.Options) == 0 {
+		return
+	}
+
+	subCommand := data.Options[0]
+	switch subCommand.Name {
+	case "unmatched":
+		handleSlashAdminUnmatched(s, i)
+	case "merge-names":
+		handleSlashAdminMergeNames(s, i, subCommand)
+	case "rebuild-ledger":
+		handleSlashAdminRebuildLedger(s, i)
+	}
+}
+
+// handleSlashAdminUnmatched handles the /docteur admin unmatched command
+func handleSlashAdminUnmatched(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	log.Printf("[Admin] Processing unmatched names request from user %s", i.Member.User.Username)
+
+	if !isLootAdmin(i) {
+		log.Printf("[Admin] Rejected: User %s is not a loot admin", i.Member.User.Username)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Only loot admins can review unmatched item names.",
+			},
+		})
+		return
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	names, err := db.ListUnmatchedNames(i.GuildID)
+	if err != nil {
+		log.Printf("[Admin] Failed to list unmatched names: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to list unmatched names: " + err.Error()),
+		})
+		return
+	}
+
+	if len(names) == 0 {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("Every item name used in this server is in the item catalog."),
+		})
+		return
+	}
+
+	var list strings.Builder
+	for idx, n := range names {
+		if idx >= unmatchedNamesListed {
+			list.WriteString(fmt.Sprintf("…and %d more\n", len(names)-unmatchedNamesListed))
+			break
+		}
+
+		times := fmt.Sprintf("used %d times", n.SeenCount)
+		if n.SeenCount == 1 {
+			times = "used once"
+		}
+		list.WriteString(fmt.Sprintf("**%s** • %s, last on %s", n.Name, times, n.LastSeenAt.Format("Jan 02")))
+		if n.Suggestion != "" {
+			list.WriteString(fmt.Sprintf(" • did you mean **%s**?", n.Suggestion))
+		}
+		list.WriteString("\n")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "🔤 Unmatched Item Names",
+		Description: "These names aren't in the item catalog, so their prices are tracked apart from the catalog item they may stand for.\n\n" + list.String(),
+		Color:       0xffa500,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+}
+
+// handleSlashAdminMergeNames handles the /docteur admin merge-names command by asking for confirmation of every affected item
+func handleSlashAdminMergeNames(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Admin] Processing merge names request from user %s", i.Member.User.Username)
+
+	if !isLootAdmin(i) {
+		log.Printf("[Admin] Rejected: User %s is not a loot admin", i.Member.User.Username)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Only loot admins can merge item names.",
+			},
+		})
+		return
+	}
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	from := strings.TrimSpace(optionMap["from"].StringValue())
+	to := strings.TrimSpace(optionMap["to"].StringValue())
+	if from == "" || to == "" {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Provide both the name to merge and the name to merge it into.",
+			},
+		})
+		return
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	// Merge into the catalog spelling of the target, so "div" merges into Divine Orb
+	to = resolveItemName(i.GuildID, to, false).Name
+	if from == to {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr(fmt.Sprintf("❌ **%s** already is the name it would be merged into.", from)),
+		})
+		return
+	}
+
+	merge, err := db.PreviewNameMerge(i.GuildID, from, to)
+	if err != nil {
+		log.Printf("[Admin] Failed to preview name merge: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to find items to merge: " + err.Error()),
+		})
+		return
+	}
+
+	var affected strings.Builder
+	for idx, item := range merge.Items {
+		if idx >= mergedItemsListed {
+			affected.WriteString(fmt.Sprintf("…and %d more\n", len(merge.Items)-mergedItemsListed))
+			break
+		}
+
+		var statusEmoji string
+		switch item.Status {
+		case "assigned":
+			statusEmoji = "⏳"
+		case "sold":
+			statusEmoji = "💰"
+		case "distributed":
+			statusEmoji = "✅"
+		case "cancelled":
+			statusEmoji = "🚫"
+		}
+		affected.WriteString(fmt.Sprintf("#%d: %s %s • %d items\n", item.ID, statusEmoji, item.Name, item.Quantity))
+	}
+	if len(merge.Items) == 0 {
+		affected.WriteString("No items use this name")
+	}
+
+	// Wait for the admin to confirm before rewriting anything
+	addPendingAction(i.ID, i.Member.User.ID, func(s *discordgo.Session, ci *discordgo.InteractionCreate) {
+		applyNameMerge(s, ci, from, to)
+	})
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Merge %s into %s", from, to),
+		Description: fmt.Sprintf("Every item and price named **%s** (in any case) will be renamed to **%s**, and **%s** will be recorded as an alias so future items are added as **%s**. Please review the changes below and confirm.", from, to, from, to),
+		Color:       0xffff00,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   fmt.Sprintf("Items (%d)", len(merge.Items)),
+				Value:  affected.String(),
+				Inline: false,
+			},
+			{
+				Name:   "Prices",
+				Value:  fmt.Sprintf("%d sales and price checks", merge.Observations),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &[]*discordgo.MessageEmbed{embed},
+		Components: confirmationButtons(i.ID),
+	})
+}
+
+// applyNameMerge merges a confirmed item name and replaces the confirmation prompt with the result
+func applyNameMerge(s *discordgo.Session, i *discordgo.InteractionCreate, from string, to string) {
+	merge, err := db.MergeItemNames(i.GuildID, from, to, actorOf(i))
+	if err != nil {
+		log.Printf("[Admin] Failed to merge %q into %q: %v", from, to, err)
+		respondUpdate(s, i, "❌ Failed to merge item names: "+err.Error(), nil)
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Item Names Merged",
+		Description: fmt.Sprintf("**%s** has been merged into **%s** by %s", from, to, i.Member.User.Mention()),
+		Color:       0x00ff00,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Items Renamed",
+				Value:  fmt.Sprintf("%d", len(merge.Items)),
+				Inline: true,
+			},
+			{
+				Name:   "Prices Merged",
+				Value:  fmt.Sprintf("%d", merge.Observations),
+				Inline: true,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	respondUpdate(s, i, "", embed)
+
+	log.Printf("[Admin] Merged %q into %q: %d items, %d prices", from, to, len(merge.Items), merge.Observations)
+}
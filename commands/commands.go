@@ -3,16 +3,16 @@ package commands
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
-	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/currency"
 	"github.com/intinig/dr-peste/db"
+	"github.com/intinig/dr-peste/distribution"
+	"github.com/intinig/dr-peste/poeitem"
 )
 
 var (
@@ -37,24 +37,36 @@ func SlashCommands() []*discordgo.ApplicationCommand {
 					Name:        "add",
 					Description: "Track a new item",
 					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "participants",
+							Description: "Mention each participant with @, the seller is added automatically (defaults to the session's)",
+							Required:    false,
+						},
 						{
 							Type:         discordgo.ApplicationCommandOptionString,
 							Name:         "name",
-							Description:  "Name of the item",
-							Required:     true,
+							Description:  "Name of the item (not needed when pasting the item text)",
+							Required:     false,
 							Autocomplete: true,
 						},
 						{
 							Type:        discordgo.ApplicationCommandOptionInteger,
 							Name:        "amount",
-							Description: "Number of items dropped",
-							Required:    true,
+							Description: "Number of items dropped (defaults to the stack size of pasted items, or 1)",
+							Required:    false,
 						},
 						{
-							Type:        discordgo.ApplicationCommandOptionString,
-							Name:        "participants",
-							Description: "List of participants - mention each user with @ (can be comma-separated or space-separated)",
-							Required:    true,
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "voice",
+							Description: "Add everyone in your voice channel as a participant, besides bots and deafened members",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "paste",
+							Description: "Paste the item text copied in game with Ctrl+C instead of typing its name",
+							Required:    false,
 						},
 						{
 							Type:        discordgo.ApplicationCommandOptionUser,
@@ -62,6 +74,12 @@ func SlashCommands() []*discordgo.ApplicationCommand {
 							Description: "User who will sell the item (defaults to you)",
 							Required:    false,
 						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "weights",
+							Description: "Share weights of participants, e.g. @carrier=2 @latejoiner=0.5 (others get 1)",
+							Required:    false,
+						},
 					},
 				},
 				{
@@ -72,7 +90,7 @@ func SlashCommands() []*discordgo.ApplicationCommand {
 						{
 							Type:        discordgo.ApplicationCommandOptionString,
 							Name:        "filter",
-							Description: "Filter items by status (pending, sold, distributed)",
+							Description: "Filter items by status (pending, sold, distributed, cancelled)",
 							Required:    false,
 							Choices: []*discordgo.ApplicationCommandOptionChoice{
 								{
@@ -87,6 +105,10 @@ func SlashCommands() []*discordgo.ApplicationCommand {
 									Name:  "Distributed",
 									Value: "distributed",
 								},
+								{
+									Name:  "Cancelled",
+									Value: "cancelled",
+								},
 							},
 						},
 					},
@@ -120,9 +142,101 @@ func SlashCommands() []*discordgo.ApplicationCommand {
 						{
 							Type:        discordgo.ApplicationCommandOptionInteger,
 							Name:        "amount",
-							Description: "Actual sale amount in Exalted Orbs",
+							Description: "Actual sale amount in the chosen currency",
 							Required:    true,
 						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "currency",
+							Description: "Currency the item was sold for (defaults to Exalted Orbs)",
+							Required:    false,
+							Choices:     currencyChoices(),
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "quantity",
+							Description: "How many of the items were sold in this trade (defaults to all that are left)",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "edit",
+					Description: "Rename, resize or reassign an item that hasn't been sold",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "item",
+							Description:  "Item name or ID",
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "name",
+							Description:  "New name of the item",
+							Required:     false,
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "quantity",
+							Description: "New number of items dropped",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Name:        "seller",
+							Description: "New seller of the item",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "cancel",
+					Description: "Cancel an item that hasn't been sold",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "item",
+							Description:  "Item name or ID",
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "reason",
+							Description: "Why the item is being cancelled",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "unsell",
+					Description: "Reverse the sale of an item and put it back up for sale",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "item",
+							Description:  "Item name or ID",
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "reason",
+							Description: "Why the sale is being reversed",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "sale",
+							Description: "ID of the sale to reverse, as shown by /docteur view (defaults to the latest)",
+							Required:    false,
+						},
 					},
 				},
 				{
@@ -130,6 +244,398 @@ func SlashCommands() []*discordgo.ApplicationCommand {
 					Name:        "profits",
 					Description: "View profit leaderboard and history",
 				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "payout",
+					Description: "Mark shares of a sold item as handed over",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "item",
+							Description:  "Item name or ID",
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Name:        "user",
+							Description: "Only mark this participant's share (defaults to everyone)",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "owed",
+					Description: "Show outstanding debts between members",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Name:        "user",
+							Description: "Only show debts involving this member",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "settle",
+					Description: "Plan the fewest transfers that clear all unpaid shares",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "cancel",
+							Description: "Cancel the open settlement instead",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "participants",
+					Description: "Change who shares in an item that hasn't been distributed",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "add",
+							Description: "Add a forgotten participant to an item",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:         discordgo.ApplicationCommandOptionString,
+									Name:         "item",
+									Description:  "Item name or ID",
+									Required:     true,
+									Autocomplete: true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionUser,
+									Name:        "user",
+									Description: "Member to add",
+									Required:    true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionNumber,
+									Name:        "weight",
+									Description: "Share weight of the member (defaults to 1)",
+									Required:    false,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "remove",
+							Description: "Remove a participant from an item",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:         discordgo.ApplicationCommandOptionString,
+									Name:         "item",
+									Description:  "Item name or ID",
+									Required:     true,
+									Autocomplete: true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionUser,
+									Name:        "user",
+									Description: "Member to remove",
+									Required:    true,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "weight",
+							Description: "Change the share weight of a participant",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:         discordgo.ApplicationCommandOptionString,
+									Name:         "item",
+									Description:  "Item name or ID",
+									Required:     true,
+									Autocomplete: true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionUser,
+									Name:        "user",
+									Description: "Participant to change",
+									Required:    true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionNumber,
+									Name:        "weight",
+									Description: "New share weight, e.g. 2 for a carrier or 0.5 for a late joiner",
+									Required:    true,
+								},
+							},
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "config",
+					Description: "Manage how sales are split in this server",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "set",
+							Description: "Change the seller commission, guild bank tax or remainder strategy (loot admins only)",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionNumber,
+									Name:        "commission",
+									Description: "Percentage of every sale the seller keeps before the split",
+									Required:    false,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionNumber,
+									Name:        "tax",
+									Description: "Percentage of every sale paid into the guild bank before the split",
+									Required:    false,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "remainder",
+									Description: "Who gets leftover orbs when participants tie",
+									Required:    false,
+									Choices:     remainderStrategyChoices(),
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "view",
+							Description: "Show the current configuration",
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "roles",
+							Description: "Show or change which Discord roles are loot admins, sellers or members (server admins only)",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionRole,
+									Name:        "role",
+									Description: "Discord role to map, leave out to list the current mapping",
+									Required:    false,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "access",
+									Description: "What members with the role can do in the ledger",
+									Required:    false,
+									Choices:     botRoleChoices(),
+								},
+							},
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "bank",
+					Description: "Manage the guild bank",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "deposit",
+							Description: "Donate orbs to the guild bank",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionInteger,
+									Name:        "amount",
+									Description: "Amount of Exalted Orbs donated",
+									Required:    true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "note",
+									Description: "What the donation is for",
+									Required:    false,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "withdraw",
+							Description: "Pay orbs out of the guild bank (loot admins only)",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionInteger,
+									Name:        "amount",
+									Description: "Amount of Exalted Orbs withdrawn",
+									Required:    true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "reason",
+									Description: "What the orbs are for, e.g. map rolling",
+									Required:    true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionUser,
+									Name:        "recipient",
+									Description: "Member receiving the orbs (defaults to you)",
+									Required:    false,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "balance",
+							Description: "Show how many orbs the guild bank holds",
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "history",
+							Description: "Show the latest taxes, donations and withdrawals",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "rates",
+					Description: "Manage currency conversion rates",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "set",
+							Description: "Set a conversion rate (loot admins only)",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "currency",
+									Description: "Currency to set the rate for",
+									Required:    true,
+									Choices:     currencyChoices(),
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionNumber,
+									Name:        "rate",
+									Description: "How many Exalted Orbs one orb of this currency is worth",
+									Required:    true,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "view",
+							Description: "Show the current conversion rates",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "price",
+					Description: "Show the price history of an item, or record a price check",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "item",
+							Description:  "Item name",
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionNumber,
+							Name:        "check",
+							Description: "Record what a single unit goes for right now, e.g. on the trade site",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "currency",
+							Description: "Currency of the price check (defaults to Exalted Orbs)",
+							Required:    false,
+							Choices:     currencyChoices(),
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "session",
+					Description: "Group the drops of a farming run and share them with its party",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "start",
+							Description: "Start a session in this channel; items added here default to its participants",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "participants",
+									Description: "Mention each member of the party with @, you are added automatically",
+									Required:    false,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionBoolean,
+									Name:        "voice",
+									Description: "Add everyone in your voice channel to the party, besides bots and deafened members",
+									Required:    false,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "name",
+									Description: "Name of the session, e.g. Breach farming",
+									Required:    false,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "end",
+							Description: "End the session running in this channel and post its summary",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "admin",
+					Description: "Keep the item names and the ledger of this server tidy",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "unmatched",
+							Description: "List item names that aren't in the item catalog (loot admins only)",
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "merge-names",
+							Description: "Rename every item and price of one name to another (loot admins only)",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:         discordgo.ApplicationCommandOptionString,
+									Name:         "from",
+									Description:  "Name to merge away, e.g. a misspelling",
+									Required:     true,
+									Autocomplete: true,
+								},
+								{
+									Type:         discordgo.ApplicationCommandOptionString,
+									Name:         "to",
+									Description:  "Name to merge it into",
+									Required:     true,
+									Autocomplete: true,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "rebuild-ledger",
+							Description: "Replay the journal to regenerate balances and check them against profit history (loot admins only)",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "audit",
+					Description: "Show every change made to an item, who made it and when",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "item",
+							Description:  "Item name or ID",
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Name:        "help",
@@ -152,6 +658,10 @@ func RegisterSlashCommands(s *discordgo.Session) {
 			handleSlashCommand(s, i)
 		} else if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
 			handleAutocomplete(s, i)
+		} else if i.Type == discordgo.InteractionMessageComponent {
+			handleComponent(s, i)
+		} else if i.Type == discordgo.InteractionModalSubmit {
+			handleModalSubmit(s, i)
 		}
 	})
 }
@@ -166,12 +676,17 @@ func handleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		return
 	}
 
+	// Ledgers are per guild, so there is nothing to suggest outside of one
+	if i.GuildID == "" || i.Member == nil {
+		return
+	}
+
 	subCommand := data.Options[0]
 	log.Printf("[Autocomplete] Processing request for /docteur %s from user %s", subCommand.Name, i.Member.User.Username)
 
 	// Check which subcommand is being used
 	switch subCommand.Name {
-	case "view", "sell", "profits":
+	case "view", "sell", "profits", "payout", "cancel", "unsell", "audit":
 		// Find the item option that needs autocomplete
 		for _, opt := range subCommand.Options {
 			if opt.Name == "item" && opt.Focused {
@@ -179,21 +694,57 @@ func handleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
 				return
 			}
 		}
-	case "add":
+	case "add", "price":
 		// Find the option that needs autocomplete
 		for _, opt := range subCommand.Options {
-			if opt.Name == "name" && opt.Focused {
+			if (opt.Name == "name" || opt.Name == "item") && opt.Focused {
 				handleItemNameAutocomplete(s, i, opt.StringValue())
 				return
 			}
 		}
+	case "participants":
+		// The item option lives on the add and remove subcommands
+		if len(subCommand.Options) == 0 {
+			return
+		}
+		for _, opt := range subCommand.Options[0].Options {
+			if opt.Name == "item" && opt.Focused {
+				handleItemAutocomplete(s, i, opt.StringValue())
+				return
+			}
+		}
+	case "admin":
+		// Both names of merge-names are item names
+		if len(subCommand.Options) == 0 {
+			return
+		}
+		for _, opt := range subCommand.Options[0].Options {
+			if (opt.Name == "from" || opt.Name == "to") && opt.Focused {
+				handleItemNameAutocomplete(s, i, opt.StringValue())
+				return
+			}
+		}
+	case "edit":
+		// Both the item and its new name can be autocompleted
+		for _, opt := range subCommand.Options {
+			if !opt.Focused {
+				continue
+			}
+			switch opt.Name {
+			case "item":
+				handleItemAutocomplete(s, i, opt.StringValue())
+			case "name":
+				handleItemNameAutocomplete(s, i, opt.StringValue())
+			}
+			return
+		}
 	}
 }
 
 // handleItemAutocomplete provides autocomplete suggestions for item names/IDs
 func handleItemAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, query string) {
 	// Get all items from the database
-	items, err := db.ListItems()
+	items, err := db.ListItems(i.GuildID)
 	if err != nil {
 		log.Printf("Error listing items for autocomplete: %v", err)
 		return
@@ -207,8 +758,18 @@ func handleItemAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate
 	subCommand := i.ApplicationCommandData().Options[0].Name
 
 	for _, item := range items {
-		// For sell command, only show pending items
-		if subCommand == "sell" && item.Status != "assigned" {
+		// For sell, edit and cancel commands, only show pending items
+		if (subCommand == "sell" || subCommand == "edit" || subCommand == "cancel") && item.Status != "assigned" {
+			continue
+		}
+
+		// Participants can only change until profits are distributed
+		if subCommand == "participants" && (item.Status == "distributed" || item.Status == "cancelled") {
+			continue
+		}
+
+		// For payout and unsell commands, only show items with at least one sale
+		if (subCommand == "payout" || subCommand == "unsell") && item.SoldQuantity == 0 {
 			continue
 		}
 
@@ -233,11 +794,17 @@ func handleItemAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate
 			// Add status indicator
 			switch item.Status {
 			case "assigned":
-				choiceName += " (⏳ Pending)"
+				if item.SoldQuantity > 0 {
+					choiceName += fmt.Sprintf(" (⏳ %d/%d Sold)", item.SoldQuantity, item.Quantity)
+				} else {
+					choiceName += " (⏳ Pending)"
+				}
 			case "sold":
 				choiceName += " (💰 Sold)"
 			case "distributed":
 				choiceName += " (✅ Distributed)"
+			case "cancelled":
+				choiceName += " (🚫 Cancelled)"
 			}
 
 			// Add the choice with the ID as the value
@@ -266,68 +833,22 @@ func handleItemAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate
 	}
 }
 
-// handleItemNameAutocomplete provides autocomplete suggestions for item names when adding new items
-func handleItemNameAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, query string) {
-	// Get all items from the database
-	items, err := db.ListItems()
-	if err != nil {
-		log.Printf("Error listing items for name autocomplete: %v", err)
-		return
-	}
-
-	// Create a map to track unique item names
-	uniqueNames := make(map[string]bool)
-
-	// Filter items based on the query
-	var choices []*discordgo.ApplicationCommandOptionChoice
-	query = strings.ToLower(query)
-
-	// First add exact matches
-	for _, item := range items {
-		itemName := item.Name
-		itemNameLower := strings.ToLower(itemName)
-
-		// Skip if we've already added this name
-		if uniqueNames[itemName] {
-			continue
-		}
-
-		// Check if the query matches the item name
-		if strings.Contains(itemNameLower, query) {
-			// Add the choice with the name as both display and value
-			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
-				Name:  itemName,
-				Value: itemName,
-			})
-
-			// Mark this name as added
-			uniqueNames[itemName] = true
-
-			// Limit to 25 choices (Discord's maximum)
-			if len(choices) >= 25 {
-				break
-			}
-		}
-	}
-
-	// Respond with the choices
-	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
-		Data: &discordgo.InteractionResponseData{
-			Choices: choices,
-		},
-	})
-
-	if err != nil {
-		log.Printf("Error responding to name autocomplete: %v", err)
-	}
-}
-
 // handleSlashCommand handles slash command interactions
 func handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	// Get command data
 	data := i.ApplicationCommandData()
 
+	// Ledgers are per guild, so commands only work inside a server
+	if i.GuildID == "" || i.Member == nil {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Docteur Peste commands can only be used inside a server.",
+			},
+		})
+		return
+	}
+
 	// Check if it's a docteur command
 	if data.Name == "docteur" && len(data.Options) > 0 {
 		subCommand := data.Options[0]
@@ -345,6 +866,34 @@ func handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
 			handleSlashSell(s, i, subCommand)
 		case "profits":
 			handleSlashProfits(s, i, subCommand)
+		case "payout":
+			handleSlashPayout(s, i, subCommand)
+		case "owed":
+			handleSlashOwed(s, i, subCommand)
+		case "settle":
+			handleSlashSettle(s, i, subCommand)
+		case "edit":
+			handleSlashEdit(s, i, subCommand)
+		case "cancel":
+			handleSlashCancel(s, i, subCommand)
+		case "unsell":
+			handleSlashUnsell(s, i, subCommand)
+		case "participants":
+			handleSlashParticipants(s, i, subCommand)
+		case "config":
+			handleSlashConfig(s, i, subCommand)
+		case "bank":
+			handleSlashBank(s, i, subCommand)
+		case "rates":
+			handleSlashRates(s, i, subCommand)
+		case "price":
+			handleSlashPrice(s, i, subCommand)
+		case "session":
+			handleSlashSession(s, i, subCommand)
+		case "admin":
+			handleSlashAdmin(s, i, subCommand)
+		case "audit":
+			handleSlashAudit(s, i, subCommand)
 		case "help":
 			handleSlashHelp(s, i)
 		case "info":
@@ -364,17 +913,37 @@ func handleSlashAdd(s *discordgo.Session, i *discordgo.InteractionCreate, data *
 		optionMap[opt.Name] = opt
 	}
 
-	// Get item name and amount
-	itemName := optionMap["name"].StringValue()
-	amount := optionMap["amount"].IntValue()
+	// Get item name and amount; pasted item text provides both
+	var itemName string
+	if nameOpt, ok := optionMap["name"]; ok {
+		itemName = strings.TrimSpace(nameOpt.StringValue())
+	}
+	var amount int64
+	if amountOpt, ok := optionMap["amount"]; ok {
+		amount = amountOpt.IntValue()
+		if amount <= 0 {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "❌ The amount must be greater than zero.",
+				},
+			})
+			return
+		}
+	}
+	var paste bool
+	if pasteOpt, ok := optionMap["paste"]; ok {
+		paste = pasteOpt.BoolValue()
+	}
 
-	// Get estimated value based on historical data
-	estimatedValue := int64(0)
-	avgPrice, err := db.GetAveragePrice(itemName)
-	if err != nil {
-		log.Printf("[Add] Warning: Failed to get average price: %v", err)
-	} else if avgPrice > 0 {
-		estimatedValue = int64(avgPrice * float64(amount))
+	if itemName == "" && !paste {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Provide the item name, or set `paste` to paste the item text copied in game.",
+			},
+		})
+		return
 	}
 
 	// Get seller, defaulting to command caller if not specified
@@ -397,133 +966,151 @@ func handleSlashAdd(s *discordgo.Session, i *discordgo.InteractionCreate, data *
 		return
 	}
 
-	participantsStr := optionMap["participants"].StringValue()
-
-	// Parse participants
-	participantMentions := strings.Split(participantsStr, ",")
-
-	// Create a map to track unique participants
-	uniqueParticipants := make(map[string]bool)
-	var participants []string
-
-	// Add seller to participants
-	uniqueParticipants[seller.ID] = true
-	participants = append(participants, seller.ID)
-
-	// Add other participants
-	botID := s.State.User.ID
-	duplicateFound := false
-	var duplicateUser string
-
-	// Regular expression to match valid Discord mentions
-	validMentionRegex := regexp.MustCompile(`^<@!?\d+>$`)
-
-	for _, mention := range participantMentions {
-		mention = strings.TrimSpace(mention)
+	// Once a server maps a seller role, only its members can hold items
+	canHold, err := canHoldItems(i.GuildID, resolvedMember(i, seller.ID))
+	if err != nil {
+		log.Printf("Error checking seller role: %v", err)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Failed to check the seller's role: " + err.Error(),
+			},
+		})
+		return
+	}
+	if !canHold {
+		log.Printf("[Add] Rejected: User %s does not have the seller role", seller.Username)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("❌ %s doesn't have a seller role on this server. Pick a seller who does.", seller.Mention()),
+			},
+		})
+		return
+	}
 
-		// Check if this is a multi-mention string (e.g., "@user1 @user2 @user3")
-		if strings.Contains(mention, " ") {
-			// Split by space and process each potential mention
-			parts := strings.Split(mention, " ")
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if part == "" {
-					continue
-				}
+	// The seller is automatically a participant
+	uniqueParticipants := map[string]bool{seller.ID: true}
+	participants := []string{seller.ID}
 
-				// Validate mention format
-				if !validMentionRegex.MatchString(part) {
-					log.Printf("[Add] Rejected: Invalid mention format: %s", part)
-					s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-						Type: discordgo.InteractionResponseChannelMessageWithSource,
-						Data: &discordgo.InteractionResponseData{
-							Content: "❌ Invalid mention format. Please use proper Discord mentions (e.g., @user1 @user2) with spaces between users.",
-						},
-					})
-					return
-				}
+	// Items added while a session runs in the channel belong to it
+	session, err := db.GetActiveSession(i.GuildID, i.ChannelID)
+	if err != nil {
+		log.Printf("[Add] Failed to get active session: %v", err)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Failed to get the session running in this channel: " + err.Error(),
+			},
+		})
+		return
+	}
 
-				userID := strings.TrimPrefix(part, "<@")
-				userID = strings.TrimPrefix(userID, "!") // Handle nickname mentions
-				userID = strings.TrimSuffix(userID, ">")
-
-				// Skip if this is the bot
-				if userID != botID {
-					if uniqueParticipants[userID] {
-						duplicateFound = true
-						duplicateUser = userID
-						break
-					}
-					uniqueParticipants[userID] = true
-					participants = append(participants, userID)
-				}
-			}
-		} else {
-			// Check for concatenated mentions (e.g., "@user1@user2")
-			if strings.Count(mention, "<@") > 1 {
-				log.Printf("[Add] Rejected: Concatenated mentions found: %s", mention)
-				s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-					Type: discordgo.InteractionResponseChannelMessageWithSource,
-					Data: &discordgo.InteractionResponseData{
-						Content: "❌ Invalid mention format. Please separate user mentions with spaces or commas (e.g., @user1 @user2 or @user1, @user2).",
-					},
-				})
-				return
-			}
+	var voice bool
+	if voiceOpt, ok := optionMap["voice"]; ok {
+		voice = voiceOpt.BoolValue()
+	}
 
-			// Validate single mention format
-			if mention != "" && !validMentionRegex.MatchString(mention) {
-				log.Printf("[Add] Rejected: Invalid mention format: %s", mention)
-				s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-					Type: discordgo.InteractionResponseChannelMessageWithSource,
-					Data: &discordgo.InteractionResponseData{
-						Content: "❌ Invalid mention format. Please use proper Discord mentions (e.g., @user1 @user2) with spaces between users.",
-					},
-				})
-				return
+	// Participants default to those of the session
+	participantsOpt, mentioned := optionMap["participants"]
+	if mentioned || voice {
+		var err error
+		participants, err = collectParticipants(s, i, participantsOpt, voice, participants, uniqueParticipants)
+		if err != nil {
+			log.Printf("[Add] Rejected: %v", err)
+			respondEphemeral(s, i, rejectionMessage(err))
+			return
+		}
+	} else if session != nil {
+		for _, userID := range session.Participants {
+			if !uniqueParticipants[userID] {
+				uniqueParticipants[userID] = true
+				participants = append(participants, userID)
 			}
+		}
+	} else {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Mention the participants, set `voice` to add your voice channel, or start a session with `/docteur session start` to fill them in automatically.",
+			},
+		})
+		return
+	}
 
-			if mention != "" {
-				userID := strings.TrimPrefix(mention, "<@")
-				userID = strings.TrimPrefix(userID, "!") // Handle nickname mentions
-				userID = strings.TrimSuffix(userID, ">")
-				// Skip if this is the bot
-				if userID != botID {
-					if uniqueParticipants[userID] {
-						duplicateFound = true
-						duplicateUser = userID
-						break
-					}
-					uniqueParticipants[userID] = true
-					participants = append(participants, userID)
+	// Parse optional share weights
+	var weights map[string]float64
+	if weightsOpt, ok := optionMap["weights"]; ok {
+		var err error
+		weights, err = parseWeights(weightsOpt.StringValue())
+		if err == nil {
+			for userID := range weights {
+				if !uniqueParticipants[userID] {
+					err = fmt.Errorf("<@%s> has a weight but is not a participant", userID)
+					break
 				}
 			}
 		}
-
-		if duplicateFound {
-			break
+		if err != nil {
+			log.Printf("[Add] Rejected: Invalid weights: %v", err)
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "❌ " + err.Error(),
+				},
+			})
+			return
 		}
 	}
 
-	// Check if we found any duplicates
-	if duplicateFound {
-		log.Printf("[Add] Rejected: User %s was listed multiple times", duplicateUser)
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("❌ User <@%s> cannot be listed multiple times. Each user can only be included once, and the seller is automatically added as a participant.", duplicateUser),
-			},
+	// Pasted items are read from a modal first; the item text decides the name and stack size
+	if paste {
+		showPasteModal(s, i, func(s *discordgo.Session, mi *discordgo.InteractionCreate, item *poeitem.Item, details *db.ItemDetails) {
+			pastedAmount := amount
+			if pastedAmount == 0 {
+				pastedAmount = item.Quantity()
+			}
+			// Names read from the game are spelled right, but magic items carry their affixes in the
+			// name, so a name that misses the catalog is still flagged for an admin to merge
+			match := resolveItemName(mi.GuildID, item.CanonicalName(), true)
+			addItem(s, mi, match, pastedAmount, seller, participants, weights, session, details)
 		})
 		return
 	}
 
+	if amount == 0 {
+		amount = 1
+	}
+
 	// Acknowledge the interaction
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 	})
 
+	addItem(s, i, resolveItemName(i.GuildID, itemName, true), amount, seller, participants, weights, session, nil)
+}
+
+// addItem adds an item to the ledger under its resolved name, assigns it to its seller and answers the deferred interaction.
+// The item belongs to session unless it is nil.
+func addItem(s *discordgo.Session, i *discordgo.InteractionCreate, name *db.NameMatch, amount int64, seller *discordgo.User, participants []string, weights map[string]float64, session *db.Session, details *db.ItemDetails) {
+	itemName := name.Name
+
+	// Get estimated value based on historical data
+	estimatedValue := int64(0)
+	estimate, hasEstimate, err := db.GetPriceEstimate(i.GuildID, itemName)
+	if err != nil {
+		log.Printf("[Add] Warning: Failed to get price estimate: %v", err)
+	} else if hasEstimate {
+		estimatedValue = estimate.Value(amount)
+	}
+
+	var sessionID int64
+	if session != nil {
+		sessionID = session.ID
+	}
+
 	// Add item to database
-	itemID, err := db.AddItem(itemName, amount, participants)
+	itemID, err := db.AddItem(i.GuildID, itemName, amount, estimatedValue, participants, weights, sessionID, details, actorOf(i))
 	if err != nil {
 		log.Printf("[Add] Failed to add item: %v", err)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
@@ -535,11 +1122,11 @@ func handleSlashAdd(s *discordgo.Session, i *discordgo.InteractionCreate, data *
 	// Format estimated value string
 	estimatedValueStr := "No historical data available"
 	if estimatedValue > 0 {
-		estimatedValueStr = fmt.Sprintf("%d Exalted Orbs (based on recent sales)", estimatedValue)
+		estimatedValueStr = formatEstimate(estimate, amount)
 	}
 
 	// Assign the item to the seller
-	err = db.AssignItem(itemID, seller.ID)
+	err = db.AssignItem(i.GuildID, itemID, seller.ID, actorOf(i))
 	if err != nil {
 		log.Printf("Error assigning item: %v", err)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
@@ -551,7 +1138,11 @@ func handleSlashAdd(s *discordgo.Session, i *discordgo.InteractionCreate, data *
 	// Format participants for display
 	var participantsDisplay string
 	for _, p := range participants {
-		participantsDisplay += fmt.Sprintf("<@%s>\n", p)
+		weight, ok := weights[p]
+		if !ok {
+			weight = 1
+		}
+		participantsDisplay += fmt.Sprintf("<@%s>%s\n", p, formatWeight(weight))
 	}
 
 	// Create response embed
@@ -589,6 +1180,30 @@ func handleSlashAdd(s *discordgo.Session, i *discordgo.InteractionCreate, data *
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
+	if session != nil {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Session",
+			Value:  session.Name,
+			Inline: true,
+		})
+	}
+
+	if details != nil {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Pasted Item",
+			Value:  formatItemDetails(details),
+			Inline: false,
+		})
+	}
+
+	if !name.Matched {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "⚠️ Unknown Item",
+			Value:  formatUnmatchedName(name),
+			Inline: false,
+		})
+	}
+
 	// Send the embed
 	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{embed},
@@ -613,7 +1228,7 @@ func handleSlashList(s *discordgo.Session, i *discordgo.InteractionCreate, data
 	})
 
 	// Get items from database
-	items, err := db.ListItems()
+	items, err := db.ListItems(i.GuildID)
 	if err != nil {
 		log.Printf("[List] Failed to list items: %v", err)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
@@ -628,7 +1243,8 @@ func handleSlashList(s *discordgo.Session, i *discordgo.InteractionCreate, data
 		if filter == "" ||
 			(filter == "pending" && item.Status == "assigned") ||
 			(filter == "sold" && item.Status == "sold") ||
-			(filter == "distributed" && item.Status == "distributed") {
+			(filter == "distributed" && item.Status == "distributed") ||
+			(filter == "cancelled" && item.Status == "cancelled") {
 			filteredItems = append(filteredItems, item)
 		}
 	}
@@ -655,8 +1271,8 @@ func handleSlashList(s *discordgo.Session, i *discordgo.InteractionCreate, data
 		maxItems = len(filteredItems)
 	}
 
-	for i := 0; i < maxItems; i++ {
-		item := filteredItems[i]
+	for idx := 0; idx < maxItems; idx++ {
+		item := filteredItems[idx]
 
 		// Get status emoji
 		var statusEmoji string
@@ -667,6 +1283,8 @@ func handleSlashList(s *discordgo.Session, i *discordgo.InteractionCreate, data
 			statusEmoji = "💰"
 		case "distributed":
 			statusEmoji = "✅"
+		case "cancelled":
+			statusEmoji = "🚫"
 		}
 
 		// Format value and seller info
@@ -677,17 +1295,23 @@ func handleSlashList(s *discordgo.Session, i *discordgo.InteractionCreate, data
 		}
 
 		if item.Status == "sold" || item.Status == "distributed" {
-			valueStr = fmt.Sprintf("%d Exalted Orbs (sold)%s", item.SaleAmount, sellerInfo)
+			valueStr = fmt.Sprintf("%d items • %s (sold)%s", item.Quantity, formatSale(item), sellerInfo)
+		} else if item.SoldQuantity > 0 {
+			valueStr = fmt.Sprintf("%d items • %d sold for %s so far%s", item.Quantity, item.SoldQuantity, formatSale(item), sellerInfo)
 		} else {
-			avgPrice, err := db.GetAveragePrice(item.Name)
+			var loggedInfo string
+			if item.EstimatedValue > 0 {
+				loggedInfo = fmt.Sprintf(" (Logged at %s)", currency.FormatBase(item.EstimatedValue))
+			}
+
+			estimate, hasEstimate, err := db.GetPriceEstimate(i.GuildID, item.Name)
 			if err != nil {
-				log.Printf("[List] Warning: Failed to get average price: %v", err)
-				valueStr = fmt.Sprintf("%d items%s", item.EstimatedValue, sellerInfo)
-			} else if avgPrice > 0 {
-				estimatedValue := int64(avgPrice * float64(item.EstimatedValue))
-				valueStr = fmt.Sprintf("%d items (Est. %d Exalted Orbs)%s", item.EstimatedValue, estimatedValue, sellerInfo)
+				log.Printf("[List] Warning: Failed to get price estimate: %v", err)
+				valueStr = fmt.Sprintf("%d items%s%s", item.Quantity, loggedInfo, sellerInfo)
+			} else if hasEstimate {
+				valueStr = fmt.Sprintf("%d items (Est. %s)%s%s", item.Quantity, formatEstimate(estimate, item.Quantity), loggedInfo, sellerInfo)
 			} else {
-				valueStr = fmt.Sprintf("%d items%s", item.EstimatedValue, sellerInfo)
+				valueStr = fmt.Sprintf("%d items%s%s", item.Quantity, loggedInfo, sellerInfo)
 			}
 		}
 
@@ -735,7 +1359,7 @@ func handleSlashView(s *discordgo.Session, i *discordgo.InteractionCreate, data
 	})
 
 	// Get item from database
-	item, err := db.GetItem(itemID)
+	item, err := db.GetItem(i.GuildID, itemID)
 	if err != nil {
 		log.Printf("Error getting item: %v", err)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
@@ -747,10 +1371,10 @@ func handleSlashView(s *discordgo.Session, i *discordgo.InteractionCreate, data
 	// Build participants field value
 	var participantsValue strings.Builder
 	for _, p := range item.Participants {
-		if item.Status == "distributed" && p.ShareAmount > 0 {
-			participantsValue.WriteString(fmt.Sprintf("<@%s>: %d Exalted Orbs\n", p.UserID, p.ShareAmount))
+		if item.SoldQuantity > 0 && p.ShareAmount > 0 {
+			participantsValue.WriteString(fmt.Sprintf("<@%s>%s: %s\n", p.UserID, formatWeight(p.Weight), currency.FormatBase(p.ShareAmount)))
 		} else {
-			participantsValue.WriteString(fmt.Sprintf("<@%s>\n", p.UserID))
+			participantsValue.WriteString(fmt.Sprintf("<@%s>%s\n", p.UserID, formatWeight(p.Weight)))
 		}
 	}
 
@@ -767,6 +1391,16 @@ func handleSlashView(s *discordgo.Session, i *discordgo.InteractionCreate, data
 	case "distributed":
 		statusEmoji = "✅ Distributed"
 		embedColor = 0x00ff00
+	case "cancelled":
+		statusEmoji = "🚫 Cancelled"
+		embedColor = 0xff0000
+	}
+
+	// Stacks that are partly sold show how many are gone
+	amountStr := fmt.Sprintf("%d items", item.Quantity)
+	if item.SoldQuantity > 0 && item.SoldQuantity < item.Quantity {
+		statusEmoji = fmt.Sprintf("⏳ Partly Sold (%d left)", item.Quantity-item.SoldQuantity)
+		amountStr = fmt.Sprintf("%d items (%d sold)", item.Quantity, item.SoldQuantity)
 	}
 
 	// Create fields array
@@ -778,7 +1412,7 @@ func handleSlashView(s *discordgo.Session, i *discordgo.InteractionCreate, data
 		},
 		{
 			Name:   "Amount",
-			Value:  fmt.Sprintf("%d items", item.EstimatedValue),
+			Value:  amountStr,
 			Inline: true,
 		},
 		{
@@ -788,26 +1422,45 @@ func handleSlashView(s *discordgo.Session, i *discordgo.InteractionCreate, data
 		},
 	}
 
-	// Add estimated value field if item is pending
+	// Add the sale amount, including the original currency, once the item is sold
+	if item.SoldQuantity > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Sale Amount",
+			Value:  formatSale(*item),
+			Inline: true,
+		})
+	}
+
+	// Add the value estimated when the item was logged
+	loggedValueStr := "No historical data available"
+	if item.EstimatedValue > 0 {
+		loggedValueStr = currency.FormatBase(item.EstimatedValue)
+	}
+	fields = append(fields, &discordgo.MessageEmbedField{
+		Name:   "Value When Logged",
+		Value:  loggedValueStr,
+		Inline: true,
+	})
+
+	// Add current estimated value field if item is pending
 	if item.Status == "assigned" {
-		avgPrice, err := db.GetAveragePrice(item.Name)
+		estimate, hasEstimate, err := db.GetPriceEstimate(i.GuildID, item.Name)
 		if err != nil {
-			log.Printf("[View] Warning: Failed to get average price: %v", err)
+			log.Printf("[View] Warning: Failed to get price estimate: %v", err)
 			fields = append(fields, &discordgo.MessageEmbedField{
-				Name:   "Estimated Value",
+				Name:   "Current Estimate",
 				Value:  "No historical data available",
 				Inline: true,
 			})
-		} else if avgPrice > 0 {
-			estimatedValue := int64(avgPrice * float64(item.EstimatedValue))
+		} else if hasEstimate {
 			fields = append(fields, &discordgo.MessageEmbedField{
-				Name:   "Estimated Value",
-				Value:  fmt.Sprintf("%d Exalted Orbs", estimatedValue),
+				Name:   "Current Estimate",
+				Value:  formatEstimate(estimate, item.Quantity-item.SoldQuantity),
 				Inline: true,
 			})
 		} else {
 			fields = append(fields, &discordgo.MessageEmbedField{
-				Name:   "Estimated Value",
+				Name:   "Current Estimate",
 				Value:  "No historical data available",
 				Inline: true,
 			})
@@ -821,6 +1474,80 @@ func handleSlashView(s *discordgo.Session, i *discordgo.InteractionCreate, data
 		Inline: false,
 	})
 
+	// Show the farming session the item dropped in
+	if item.SessionID != 0 {
+		session, err := db.GetSession(i.GuildID, item.SessionID)
+		if err != nil {
+			log.Printf("[View] Warning: Failed to get item session: %v", err)
+		} else {
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name:   "Session",
+				Value:  fmt.Sprintf("%s (started %s)", session.Name, session.StartedAt.Format("Jan 02 15:04")),
+				Inline: false,
+			})
+		}
+	}
+
+	// Show what was read from the item text of pasted items
+	details, err := db.GetItemDetails(i.GuildID, item.ID)
+	if err != nil {
+		log.Printf("[View] Warning: Failed to get item details: %v", err)
+	} else if details != nil {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Pasted Item",
+			Value:  formatItemDetails(details),
+			Inline: false,
+		})
+	}
+
+	// List every trade of the stack, so a single sale can be reversed
+	sales, err := db.GetItemSales(i.GuildID, item.ID)
+	if err != nil {
+		log.Printf("[View] Warning: Failed to get item sales: %v", err)
+	} else if len(sales) > 0 {
+		var salesValue strings.Builder
+		for _, sale := range sales {
+			salesValue.WriteString(formatItemSale(sale) + "\n")
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Sales",
+			Value:  salesValue.String(),
+			Inline: false,
+		})
+	}
+
+	// Explain who received the leftover orbs of uneven divisions
+	awards, err := db.GetItemRemainderAwards(i.GuildID, item.ID)
+	if err != nil {
+		log.Printf("[View] Warning: Failed to get remainder awards: %v", err)
+	} else if len(awards) > 0 {
+		var awardsValue strings.Builder
+		for _, award := range awards {
+			awardsValue.WriteString(formatRemainderAward(award) + "\n")
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Leftover Orbs",
+			Value:  awardsValue.String(),
+			Inline: false,
+		})
+	}
+
+	// Add cancellations and reversed sales so they can be explained later
+	reversals, err := db.GetItemReversals(i.GuildID, item.ID)
+	if err != nil {
+		log.Printf("[View] Warning: Failed to get item reversals: %v", err)
+	} else if len(reversals) > 0 {
+		var historyValue strings.Builder
+		for _, reversal := range reversals {
+			historyValue.WriteString(formatReversal(reversal) + "\n")
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "History",
+			Value:  historyValue.String(),
+			Inline: false,
+		})
+	}
+
 	// Add dates
 	fields = append(fields, &discordgo.MessageEmbedField{
 		Name:   "Created At",
@@ -874,7 +1601,46 @@ func handleSlashSell(s *discordgo.Session, i *discordgo.InteractionCreate, data
 		return
 	}
 
-	saleAmount := optionMap["amount"].IntValue()
+	originalAmount := optionMap["amount"].IntValue()
+	if originalAmount <= 0 {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ The sale amount must be greater than zero.",
+			},
+		})
+		return
+	}
+
+	// Get the quantity sold, defaulting to everything that is left
+	var quantity int64
+	if quantityOpt, ok := optionMap["quantity"]; ok {
+		quantity = quantityOpt.IntValue()
+		if quantity <= 0 {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "❌ The quantity must be greater than zero.",
+				},
+			})
+			return
+		}
+	}
+
+	// Get the sale currency, defaulting to the base currency
+	saleCurrency := currency.Base
+	if currencyOpt, ok := optionMap["currency"]; ok {
+		saleCurrency, err = currency.Parse(currencyOpt.StringValue())
+		if err != nil {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "❌ " + err.Error(),
+				},
+			})
+			return
+		}
+	}
 
 	// Acknowledge the interaction
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -882,7 +1648,7 @@ func handleSlashSell(s *discordgo.Session, i *discordgo.InteractionCreate, data
 	})
 
 	// Get item to check if the user is the seller
-	item, err := db.GetItem(itemID)
+	item, err := db.GetItem(i.GuildID, itemID)
 	if err != nil {
 		log.Printf("Error getting item: %v", err)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
@@ -895,105 +1661,217 @@ func handleSlashSell(s *discordgo.Session, i *discordgo.InteractionCreate, data
 	if item.Status == "sold" || item.Status == "distributed" {
 		log.Printf("[Sell] Rejected: Item #%d is already sold", itemID)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-			Content: strPtr(fmt.Sprintf("❌ This item has already been sold for %d Exalted Orbs.", item.SaleAmount)),
+			Content: strPtr(fmt.Sprintf("❌ This item has already been sold for %s.", formatSale(*item))),
+		})
+		return
+	}
+
+	// Cancelled items can't be sold
+	if item.Status == "cancelled" {
+		log.Printf("[Sell] Rejected: Item #%d is cancelled", itemID)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ This item has been cancelled."),
 		})
 		return
 	}
 
-	// Check if the user is the seller
-	if item.AssignedTo != i.Member.User.ID {
+	// Check if the user is the seller; loot admins can sell on the seller's behalf
+	allowed, override := canActOnItem(i, item)
+	if !allowed {
 		log.Printf("[Sell] Rejected: User %s is not the seller of item #%d", i.Member.User.Username, itemID)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-			Content: strPtr("❌ Only the assigned seller can mark this item as sold."),
+			Content: strPtr("❌ Only the assigned seller or a loot admin can mark this item as sold."),
 		})
 		return
 	}
 
-	// Calculate shares for each participant
-	numParticipants := int64(len(item.Participants))
-	baseShareAmount := saleAmount / numParticipants
-	remainder := saleAmount % numParticipants
+	// Someone has to receive the proceeds
+	if len(item.Participants) == 0 {
+		log.Printf("[Sell] Rejected: Item #%d has no participants", itemID)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ This item has no participants to share the sale. Add them with `/docteur participants add` first."),
+		})
+		return
+	}
+
+	// Check the quantity against what is left of the stack
+	remaining := item.Quantity - item.SoldQuantity
+	if quantity == 0 {
+		quantity = remaining
+	}
+	if quantity > remaining {
+		log.Printf("[Sell] Rejected: Only %d of item #%d left to sell", remaining, itemID)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr(fmt.Sprintf("❌ Only %d of this item are left to sell.", remaining)),
+		})
+		return
+	}
 
-	// Create a map to track individual shares
-	shares := make(map[string]int64)
+	// Normalize the sale amount to the base currency
+	saleAmount := originalAmount
+	var conversionInfo string
+	if !saleCurrency.IsBase() {
+		rate, err := db.GetCurrencyRate(i.GuildID, string(saleCurrency))
+		if err != nil {
+			log.Printf("Error getting currency rate: %v", err)
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("❌ Failed to get conversion rate: " + err.Error()),
+			})
+			return
+		}
+		if rate == nil {
+			log.Printf("[Sell] Rejected: No conversion rate set for %s", saleCurrency)
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr(fmt.Sprintf("❌ No conversion rate is set for %s. Ask an admin to run `/docteur rates set`.", saleCurrency.Name())),
+			})
+			return
+		}
 
-	// Initialize all shares with the base amount
-	for _, p := range item.Participants {
-		shares[p.UserID] = baseShareAmount
+		saleAmount = currency.ToBase(originalAmount, rate.Rate)
+		conversionInfo = fmt.Sprintf("%s at %s %s/%s (rate set %s)",
+			currency.Format(originalAmount, saleCurrency), formatRate(rate.Rate), currency.Base.ShortName(),
+			saleCurrency.ShortName(), rate.CreatedAt.Format("Jan 02, 2006"))
 	}
 
-	// Handle remainder distribution
-	var extraInfo string
-	if remainder > 0 {
-		// First, give one extra to the seller
-		shares[item.AssignedTo]++
-		remainder--
+	// Take the seller commission and guild bank tax off the top
+	config, err := db.GetGuildConfig(i.GuildID)
+	if err != nil {
+		log.Printf("Error getting guild config: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get configuration: " + err.Error()),
+		})
+		return
+	}
+	commission, tax := config.Cuts(saleAmount)
+	netAmount := saleAmount - commission - tax
 
-		if remainder == 0 {
-			extraInfo = fmt.Sprintf("Seller <@%s> received 1 extra Exalted Orb due to uneven division.", item.AssignedTo)
-		} else {
-			extraInfo = fmt.Sprintf("Seller <@%s> received 1 extra Exalted Orb. ", item.AssignedTo)
+	// Weigh each participant's share, 1 unless set otherwise
+	numParticipants := int64(len(item.Participants))
+	weights := make(map[string]float64, len(item.Participants))
+	weighted := false
+	for _, p := range item.Participants {
+		weights[p.UserID] = p.Weight
+		if p.Weight != 1 {
+			weighted = true
+		}
+	}
 
-			// If there's still a remainder, distribute randomly (but not to seller again)
-			var otherParticipants []string
-			for _, p := range item.Participants {
-				if p.UserID != item.AssignedTo {
-					otherParticipants = append(otherParticipants, p.UserID)
-				}
-			}
+	// Leftover orbs go by largest remainder; the guild's strategy breaks ties
+	strategy, err := distribution.ParseStrategy(config.RemainderStrategy)
+	if err != nil {
+		log.Printf("[Sell] Warning: %v, falling back to seller first", err)
+		strategy = distribution.SellerFirst
+	}
+
+	var participantIDs []string
+	for _, p := range item.Participants {
+		participantIDs = append(participantIDs, p.UserID)
+	}
 
-			// Shuffle the participants to randomize distribution
-			rand.Seed(time.Now().UnixNano())
-			rand.Shuffle(len(otherParticipants), func(i, j int) {
-				otherParticipants[i], otherParticipants[j] = otherParticipants[j], otherParticipants[i]
+	var wins map[string]int
+	if strategy == distribution.RoundRobin {
+		wins, err = db.GetRemainderWins(i.GuildID)
+		if err != nil {
+			log.Printf("Error getting remainder wins: %v", err)
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("❌ Failed to get past remainder awards: " + err.Error()),
 			})
+			return
+		}
+	}
 
-			// Distribute remaining exalted orbs (max 1 per person)
-			var luckyParticipants []string
-			for i := 0; i < int(remainder) && i < len(otherParticipants); i++ {
-				shares[otherParticipants[i]]++
-				luckyParticipants = append(luckyParticipants, fmt.Sprintf("<@%s>", otherParticipants[i]))
-			}
+	var seed int64
+	if strategy == distribution.SeededRandom {
+		seed = time.Now().UnixNano()
+	}
 
-			if len(luckyParticipants) > 0 {
-				extraInfo += fmt.Sprintf("Additionally, %s randomly received 1 extra Exalted Orb each due to uneven division.", strings.Join(luckyParticipants, ", "))
-			}
+	priority := strategy.Order(participantIDs, item.AssignedTo, wins, seed)
+	split := distribution.Proportional(netAmount, weights, priority)
+	shares := split.Shares
+
+	// Record and explain who received the leftover orbs
+	var awards []db.RemainderAward
+	var extraInfo string
+	if len(split.Awards) > 0 {
+		var luckyParticipants []string
+		for _, award := range split.Awards {
+			reason := strategy.Reason(award, item.AssignedTo, wins, seed)
+			awards = append(awards, db.RemainderAward{
+				UserID:   award.Member,
+				Strategy: string(strategy),
+				Seed:     seed,
+				Reason:   reason,
+			})
+			luckyParticipants = append(luckyParticipants, fmt.Sprintf("<@%s> (%s)", award.Member, reason))
 		}
+		extraInfo = fmt.Sprintf("%d leftover %s from uneven division, one each to %s. Strategy: %s.",
+			len(split.Awards), currency.Base.Name(), strings.Join(luckyParticipants, ", "), strategy.Name())
 	}
 
-	// Mark item as sold and distribute profits
-	err = db.MarkItemAsSoldAndDistribute(itemID, saleAmount, shares)
+	// Record the sale and distribute profits
+	actor := overrideActor(i, override, "sell", fmt.Sprintf("of %d for %s on behalf of <@%s>", quantity, currency.FormatBase(saleAmount), item.AssignedTo))
+	sale, err := db.RecordSale(i.GuildID, itemID, db.SaleSplit{
+		Quantity:       quantity,
+		Amount:         saleAmount,
+		Currency:       string(saleCurrency),
+		OriginalAmount: originalAmount,
+		Shares:         shares,
+		Commission:     commission,
+		Tax:            tax,
+		Awards:         awards,
+	}, actor)
 	if err != nil {
-		log.Printf("Error marking item as sold and distributed: %v", err)
+		log.Printf("Error recording sale: %v", err)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
 			Content: strPtr("❌ Failed to process sale: " + err.Error()),
 		})
 		return
 	}
 
+	logOverride(i, actor, itemID)
+
 	// Build participants field value
 	var participantsValue strings.Builder
 	for _, p := range item.Participants {
-		participantsValue.WriteString(fmt.Sprintf("<@%s>: %d Exalted Orbs\n", p.UserID, shares[p.UserID]))
+		participantsValue.WriteString(fmt.Sprintf("<@%s>%s: %s\n", p.UserID, formatWeight(p.Weight), currency.FormatBase(shares[p.UserID])))
+	}
+
+	// Equal splits show the share per person, weighted ones the total weight
+	splitField := &discordgo.MessageEmbedField{
+		Name:   "Base Share",
+		Value:  fmt.Sprintf("%s per person", currency.FormatBase(netAmount/numParticipants)),
+		Inline: true,
+	}
+	if weighted {
+		var totalWeight float64
+		for _, w := range weights {
+			totalWeight += w
+		}
+		splitField = &discordgo.MessageEmbedField{
+			Name:   "Split",
+			Value:  fmt.Sprintf("Weighted, total weight %s", strconv.FormatFloat(totalWeight, 'f', -1, 64)),
+			Inline: true,
+		}
 	}
 
 	// Create fields for the embed
 	fields := []*discordgo.MessageEmbedField{
 		{
 			Name:   "Sale Amount",
-			Value:  fmt.Sprintf("%d Exalted Orbs", saleAmount),
+			Value:  currency.FormatBase(saleAmount),
 			Inline: true,
 		},
 		{
-			Name:   "Participants",
-			Value:  fmt.Sprintf("%d", numParticipants),
+			Name:   "Quantity Sold",
+			Value:  fmt.Sprintf("%d × %s each", sale.Quantity, currency.FormatBase(int64(sale.UnitPrice()))),
 			Inline: true,
 		},
 		{
-			Name:   "Base Share",
-			Value:  fmt.Sprintf("%d Exalted Orbs per person", baseShareAmount),
+			Name:   "Participants",
+			Value:  fmt.Sprintf("%d", numParticipants),
 			Inline: true,
 		},
+		splitField,
 		{
 			Name:   "Distribution",
 			Value:  participantsValue.String(),
@@ -1001,6 +1879,45 @@ func handleSlashSell(s *discordgo.Session, i *discordgo.InteractionCreate, data
 		},
 	}
 
+	// Break down what came off the top before the split
+	if commission > 0 || tax > 0 {
+		fields = append(fields,
+			&discordgo.MessageEmbedField{
+				Name:   "Seller Commission",
+				Value:  fmt.Sprintf("<@%s>: %s (%s%%)", item.AssignedTo, currency.FormatBase(commission), formatPercent(config.CommissionPct)),
+				Inline: true,
+			},
+			&discordgo.MessageEmbedField{
+				Name:   "Guild Bank Tax",
+				Value:  fmt.Sprintf("%s (%s%%)", currency.FormatBase(tax), formatPercent(config.BankTaxPct)),
+				Inline: true,
+			},
+			&discordgo.MessageEmbedField{
+				Name:   "Net Shares",
+				Value:  currency.FormatBase(netAmount),
+				Inline: true,
+			},
+		)
+	}
+
+	// Show the original trade if it was made in another currency
+	if conversionInfo != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Converted From",
+			Value:  conversionInfo,
+			Inline: false,
+		})
+	}
+
+	// Loot admins selling for someone else are called out, the seller still holds the orbs
+	if override {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Sold By",
+			Value:  fmt.Sprintf("%s on behalf of <@%s>", i.Member.User.Mention(), item.AssignedTo),
+			Inline: false,
+		})
+	}
+
 	// Add extra info field if there was a remainder
 	if extraInfo != "" {
 		fields = append(fields, &discordgo.MessageEmbedField{
@@ -1010,10 +1927,19 @@ func handleSlashSell(s *discordgo.Session, i *discordgo.InteractionCreate, data
 		})
 	}
 
+	// Partial sales keep the rest of the stack up for sale
+	title := "Item Sold and Profits Distributed"
+	description := fmt.Sprintf("Item **%s** (ID: **%d**) has been sold and profits have been distributed", item.Name, itemID)
+	if left := remaining - sale.Quantity; left > 0 {
+		title = "Stack Sold and Profits Distributed"
+		description = fmt.Sprintf("%d of **%s** (ID: **%d**) have been sold (sale #%d) and profits have been distributed. %d left to sell.",
+			sale.Quantity, item.Name, itemID, sale.ID, left)
+	}
+
 	// Create response embed
 	embed := &discordgo.MessageEmbed{
-		Title:       "Item Sold and Profits Distributed",
-		Description: fmt.Sprintf("Item **%s** (ID: **%d**) has been sold and profits have been distributed", item.Name, itemID),
+		Title:       title,
+		Description: description,
 		Color:       0x00ff00,
 		Fields:      fields,
 		Timestamp:   time.Now().Format(time.RFC3339),
@@ -1024,7 +1950,7 @@ func handleSlashSell(s *discordgo.Session, i *discordgo.InteractionCreate, data
 		Embeds: &[]*discordgo.MessageEmbed{embed},
 	})
 
-	log.Printf("[Sell] Successfully sold item #%d for %d Exalted Orbs", itemID, saleAmount)
+	log.Printf("[Sell] Successfully sold %d of item #%d for %s (%s) as sale #%d", sale.Quantity, itemID, currency.Format(originalAmount, saleCurrency), currency.FormatBase(saleAmount), sale.ID)
 }
 
 // handleSlashProfits handles the /docteur profits command
@@ -1036,53 +1962,24 @@ func handleSlashProfits(s *discordgo.Session, i *discordgo.InteractionCreate, da
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 	})
 
-	// Create a map to store user profits
-	type UserProfit struct {
-		UserID     string
-		Total      int64
-		LastProfit time.Time
-	}
-	userProfits := make(map[string]*UserProfit)
-
-	// Get all profit history records
-	records, err := db.GetAllProfitHistory()
+	// Members' balances come from the journal; the bank is shown separately
+	balances, err := db.GetAccountBalances(i.GuildID, db.AccountUser)
 	if err != nil {
-		log.Printf("Error getting profit history: %v", err)
+		log.Printf("Error getting ledger balances: %v", err)
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-			Content: strPtr("❌ Failed to get profit history: " + err.Error()),
+			Content: strPtr("❌ Failed to get profit balances: " + err.Error()),
 		})
 		return
 	}
 
-	// Calculate totals and last profit dates for each user
-	for _, record := range records {
-		profit, exists := userProfits[record.UserID]
-		if !exists {
-			profit = &UserProfit{
-				UserID: record.UserID,
-			}
-			userProfits[record.UserID] = profit
-		}
-
-		profit.Total += record.Amount
-		if record.TransactionDate.After(profit.LastProfit) {
-			profit.LastProfit = record.TransactionDate
-		}
-	}
-
-	// Convert map to slice for sorting
-	var profits []UserProfit
-	for _, p := range userProfits {
-		if p.Total > 0 {
-			profits = append(profits, *p)
+	// Balances are already sorted from the highest
+	var profits []db.AccountBalance
+	for _, balance := range balances {
+		if balance.Balance > 0 {
+			profits = append(profits, balance)
 		}
 	}
 
-	// Sort profits by total amount (descending)
-	sort.Slice(profits, func(i, j int) bool {
-		return profits[i].Total > profits[j].Total
-	})
-
 	// Create the leaderboard field
 	var leaderboard strings.Builder
 	for i, profit := range profits {
@@ -1100,10 +1997,10 @@ func handleSlashProfits(s *discordgo.Session, i *discordgo.InteractionCreate, da
 		}
 
 		// Format the last profit date
-		lastProfitStr := profit.LastProfit.Format("Jan 02")
+		lastProfitStr := profit.LastEntryAt.Format("Jan 02")
 
-		leaderboard.WriteString(fmt.Sprintf("%s <@%s>: %d Exalted Orbs (Last: %s)\n",
-			prefix, profit.UserID, profit.Total, lastProfitStr))
+		leaderboard.WriteString(fmt.Sprintf("%s <@%s>: %s (Last: %s)\n",
+			prefix, profit.Account.ID, currency.FormatBase(profit.Balance), lastProfitStr))
 	}
 
 	// Create fields for the embed
@@ -1118,14 +2015,26 @@ func handleSlashProfits(s *discordgo.Session, i *discordgo.InteractionCreate, da
 	// Add total profits across all users
 	var totalProfits int64
 	for _, p := range profits {
-		totalProfits += p.Total
+		totalProfits += p.Balance
 	}
 	fields = append(fields, &discordgo.MessageEmbedField{
 		Name:   "Total Group Profits",
-		Value:  fmt.Sprintf("%d Exalted Orbs", totalProfits),
+		Value:  currency.FormatBase(totalProfits),
 		Inline: false,
 	})
 
+	// Show the guild bank under the group total
+	bankBalance, err := db.GetBankBalance(i.GuildID)
+	if err != nil {
+		log.Printf("Error getting bank balance: %v", err)
+	} else if bankBalance != 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "🏦 Guild Bank",
+			Value:  currency.FormatBase(bankBalance),
+			Inline: false,
+		})
+	}
+
 	// Create response embed
 	embed := &discordgo.MessageEmbed{
 		Title:       "Profit Leaderboard",
@@ -1163,7 +2072,7 @@ func handleSlashHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name:   "/docteur add",
-				Value:  "Track a new item",
+				Value:  "Track a new item, typed in or pasted from the game with `paste`",
 				Inline: false,
 			},
 			{
@@ -1178,7 +2087,7 @@ func handleSlashHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 			},
 			{
 				Name:   "/docteur sell",
-				Value:  "Mark an item as sold and automatically distribute profits",
+				Value:  "Mark an item, or part of a stack, as sold and automatically distribute profits",
 				Inline: false,
 			},
 			{
@@ -1186,6 +2095,76 @@ func handleSlashHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 				Value:  "View profit leaderboard and history",
 				Inline: false,
 			},
+			{
+				Name:   "/docteur edit",
+				Value:  "Rename, resize or reassign an unsold item (seller or loot admin)",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur participants",
+				Value:  "Add or remove participants of an item, or change their share weight, before it is distributed (seller or loot admin)",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur cancel",
+				Value:  "Cancel an unsold item (seller or loot admin)",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur unsell",
+				Value:  "Reverse a sale with compensating entries (seller or loot admin)",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur payout",
+				Value:  "Mark shares of an item you sold as handed over",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur owed",
+				Value:  "Show who still owes whom",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur settle",
+				Value:  "Net all unpaid shares into the fewest possible transfers",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur config",
+				Value:  "View or set (loot admins only) the seller commission, guild bank tax and remainder strategy, or map Discord roles to loot admins, sellers and members",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur bank",
+				Value:  "Deposit to, withdraw from (loot admins only) or check the guild bank",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur price",
+				Value:  "Show the price history, trend and estimate of an item, or record a price check",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur rates",
+				Value:  "View or set (loot admins only) Divine and Chaos Orb conversion rates",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur session",
+				Value:  "Start a farming session so items added in the channel default to its party, or end it and post a summary",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur audit",
+				Value:  "Show an item's full timeline: every change with its author, before and after values, and the interaction it came from",
+				Inline: false,
+			},
+			{
+				Name:   "/docteur admin",
+				Value:  "List item names that aren't in the item catalog, merge duplicate names and their price history, or rebuild the ledger's balances from its journal (loot admins only)",
+				Inline: false,
+			},
 		},
 		Footer: &discordgo.MessageEmbedFooter{
 			Text: "Docteur Peste - Path of Exile 2 Loot Tracker",
@@ -1216,11 +2195,21 @@ func handleSlashInfo(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	hours := int(uptime.Hours()) % 24
 	minutes := int(uptime.Minutes()) % 60
 
-	response := fmt.Sprintf("```\n%s\nUptime: %d days, %d hours, %d minutes```",
+	// Show how fresh the market price fallback is
+	marketInfo := "Market prices: none imported"
+	snapshot, err := db.GetLatestMarketSnapshot()
+	if err != nil {
+		log.Printf("[Info] Warning: Failed to get market snapshot: %v", err)
+	} else if snapshot != nil {
+		marketInfo = fmt.Sprintf("Market prices: %d items, imported %s", snapshot.ItemCount, snapshot.ImportedAt.Format("Jan 02, 2006 15:04"))
+	}
+
+	response := fmt.Sprintf("```\n%s\nUptime: %d days, %d hours, %d minutes\n%s```",
 		string(versionData),
 		days,
 		hours,
-		minutes)
+		minutes,
+		marketInfo)
 
 	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
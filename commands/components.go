@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// handleComponent handles button presses on messages sent by the bot.
+// Custom IDs have the form "<action>:<argument>".
+func handleComponent(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// Ledgers are per guild, so buttons only work inside a server
+	if i.GuildID == "" || i.Member == nil {
+		return
+	}
+
+	customID := i.MessageComponentData().CustomID
+	action, argument, _ := strings.Cut(customID, ":")
+	log.Printf("[Component] Processing %s from user %s", action, i.Member.User.Username)
+
+	switch action {
+	case shareConfirmPrefix:
+		handleShareConfirm(s, i, argument)
+	case settleTransferPrefix:
+		handleSettleTransfer(s, i, argument)
+	case confirmPrefix:
+		handleConfirm(s, i, argument)
+	case abortPrefix:
+		handleAbort(s, i, argument)
+	default:
+		log.Printf("[Component] Unknown component action: %s", customID)
+	}
+}
+
+// buttonRows lays out buttons in action rows of up to five buttons each
+func buttonRows(buttons []discordgo.MessageComponent) *[]discordgo.MessageComponent {
+	rows := []discordgo.MessageComponent{}
+	for start := 0; start < len(buttons); start += 5 {
+		end := start + 5
+		if end > len(buttons) {
+			end = len(buttons)
+		}
+		rows = append(rows, discordgo.ActionsRow{Components: buttons[start:end]})
+	}
+	return &rows
+}
+
+// disableButton returns a copy of a message's components with one button disabled and relabeled
+func disableButton(components []discordgo.MessageComponent, customID string, label string) []discordgo.MessageComponent {
+	var updated []discordgo.MessageComponent
+	for _, component := range components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			updated = append(updated, component)
+			continue
+		}
+
+		var rowComponents []discordgo.MessageComponent
+		for _, rowComponent := range row.Components {
+			if button, ok := rowComponent.(*discordgo.Button); ok && button.CustomID == customID {
+				disabled := *button
+				disabled.Disabled = true
+				disabled.Label = label
+				rowComponent = disabled
+			}
+			rowComponents = append(rowComponents, rowComponent)
+		}
+		updated = append(updated, discordgo.ActionsRow{Components: rowComponents})
+	}
+	return updated
+}
+
+// respondEphemeral replies to an interaction with a message only the caller can see
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+// userDisplayName returns a user's name for places where mentions don't render, like button labels
+func userDisplayName(s *discordgo.Session, userID string) string {
+	user, err := s.User(userID)
+	if err != nil {
+		log.Printf("Error getting user info: %v", err)
+		return "Unknown User"
+	}
+	return user.Username
+}
+
+// rejectionMessage turns an error into a reply for the member who caused it, capitalised and ending in a full stop
+func rejectionMessage(err error) string {
+	message := strings.TrimSpace(err.Error())
+	if first, size := utf8.DecodeRuneInString(message); size > 0 {
+		message = string(unicode.ToUpper(first)) + message[size:]
+	}
+	if !strings.HasSuffix(message, ".") && !strings.HasSuffix(message, "!") && !strings.HasSuffix(message, "?") {
+		message += "."
+	}
+	return "❌ " + message
+}
@@ -0,0 +1,368 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/db"
+	"github.com/intinig/dr-peste/distribution"
+)
+
+// handleSlashConfig handles the /docteur config command group
+func handleSlashConfig(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(data.Options) == 0 {
+		return
+	}
+
+	subCommand := data.Options[0]
+	switch subCommand.Name {
+	case "set":
+		handleSlashConfigSet(s, i, subCommand)
+	case "view":
+		handleSlashConfigView(s, i)
+	case "roles":
+		handleSlashConfigRoles(s, i, subCommand)
+	}
+}
+
+// handleSlashConfigSet handles the /docteur config set command
+func handleSlashConfigSet(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Config] Processing config update from user %s", i.Member.User.Username)
+
+	// Only admins can change how sales are split
+	if !isLootAdmin(i) {
+		log.Printf("[Config] Rejected: User %s is not a loot admin", i.Member.User.Username)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Only loot admins can change the configuration.",
+			},
+		})
+		return
+	}
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	if len(optionMap) == 0 {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Provide at least one setting to change.",
+			},
+		})
+		return
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	config, err := db.GetGuildConfig(i.GuildID)
+	if err != nil {
+		log.Printf("[Config] Failed to get config: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get configuration: " + err.Error()),
+		})
+		return
+	}
+
+	if commissionOpt, ok := optionMap["commission"]; ok {
+		config.CommissionPct = commissionOpt.FloatValue()
+	}
+	if taxOpt, ok := optionMap["tax"]; ok {
+		config.BankTaxPct = taxOpt.FloatValue()
+	}
+	if remainderOpt, ok := optionMap["remainder"]; ok {
+		strategy, err := distribution.ParseStrategy(remainderOpt.StringValue())
+		if err != nil {
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("❌ " + err.Error()),
+			})
+			return
+		}
+		config.RemainderStrategy = string(strategy)
+	}
+
+	if err := db.SetGuildConfig(config, actorOf(i)); err != nil {
+		log.Printf("[Config] Failed to save config: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to save configuration: " + err.Error()),
+		})
+		return
+	}
+
+	embed := configEmbed(config)
+	embed.Title = "Configuration Updated"
+	embed.Color = 0x00ff00
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Config] Set commission to %s%%, bank tax to %s%% and remainder strategy to %s",
+		formatPercent(config.CommissionPct), formatPercent(config.BankTaxPct), config.RemainderStrategy)
+}
+
+// handleSlashConfigView handles the /docteur config view command
+func handleSlashConfigView(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	log.Printf("[Config] Processing config view from user %s", i.Member.User.Username)
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	config, err := db.GetGuildConfig(i.GuildID)
+	if err != nil {
+		log.Printf("[Config] Failed to get config: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get configuration: " + err.Error()),
+		})
+		return
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{configEmbed(config)},
+	})
+}
+
+// handleSlashConfigRoles handles the /docteur config roles command. Without a role it lists the mapping.
+func handleSlashConfigRoles(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Config] Processing roles request from user %s", i.Member.User.Username)
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	roleOpt, mapping := optionMap["role"]
+	accessOpt, hasAccess := optionMap["access"]
+	if mapping != hasAccess {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Provide both a role and its access to change the mapping, or neither to list it.",
+			},
+		})
+		return
+	}
+
+	// Loot admins can't hand out loot admin, only whoever manages the server can
+	if mapping && !isGuildAdmin(i) {
+		log.Printf("[Config] Rejected: User %s is not a server admin", i.Member.User.Username)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Only server admins can change which roles are loot admins, sellers or members.",
+			},
+		})
+		return
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	title := "Roles"
+	color := 0x00ffff
+	if mapping {
+		roleID := roleOpt.RoleValue(nil, "").ID
+		access := accessOpt.StringValue()
+
+		var err error
+		if access == "none" {
+			var removed bool
+			removed, err = db.RemoveGuildRole(i.GuildID, roleID, actorOf(i))
+			if err == nil && !removed {
+				s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+					Content: strPtr(fmt.Sprintf("<@&%s> isn't mapped to a bot role.", roleID)),
+				})
+				return
+			}
+		} else {
+			err = db.SetGuildRole(i.GuildID, roleID, access, actorOf(i))
+		}
+		if err != nil {
+			log.Printf("[Config] Failed to map role %s to %s: %v", roleID, access, err)
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("❌ Failed to save role: " + err.Error()),
+			})
+			return
+		}
+
+		log.Printf("[Config] Mapped role %s to %s", roleID, access)
+		title = "Roles Updated"
+		color = 0x00ff00
+	}
+
+	roles, err := db.GetGuildRoles(i.GuildID)
+	if err != nil {
+		log.Printf("[Config] Failed to get roles: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get roles: " + err.Error()),
+		})
+		return
+	}
+
+	embed := rolesEmbed(roles)
+	embed.Title = title
+	embed.Color = color
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+}
+
+// configEmbed renders a guild's configuration
+func configEmbed(config *db.GuildConfig) *discordgo.MessageEmbed {
+	fields := []*discordgo.MessageEmbedField{
+		{
+			Name:   "Seller Commission",
+			Value:  formatPercent(config.CommissionPct) + "%",
+			Inline: true,
+		},
+		{
+			Name:   "Guild Bank Tax",
+			Value:  formatPercent(config.BankTaxPct) + "%",
+			Inline: true,
+		},
+		{
+			Name:   "Remainder Strategy",
+			Value:  formatRemainderStrategy(config.RemainderStrategy),
+			Inline: true,
+		},
+	}
+
+	if config.UpdatedBy != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Last Changed",
+			Value:  fmt.Sprintf("by <@%s> on %s", config.UpdatedBy, config.UpdatedAt.Format("Jan 02, 2006 15:04")),
+			Inline: false,
+		})
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "Configuration",
+		Description: "Commission and tax are taken off the top of every sale before it is split; leftover orbs go by largest remainder, the strategy breaks ties",
+		Color:       0x00ffff,
+		Fields:      fields,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+}
+
+// rolesEmbed renders the mapping of Discord roles to bot roles, grouped by bot role
+func rolesEmbed(roles []db.GuildRole) *discordgo.MessageEmbed {
+	var fields []*discordgo.MessageEmbedField
+	for rank := len(db.BotRoles) - 1; rank >= 0; rank-- {
+		botRole := db.BotRoles[rank]
+
+		var mentions strings.Builder
+		for _, role := range roles {
+			if role.BotRole == botRole {
+				mentions.WriteString(fmt.Sprintf("<@&%s>\n", role.RoleID))
+			}
+		}
+		if botRole == db.RoleLootAdmin {
+			mentions.WriteString("Server admins\n")
+		}
+		if mentions.Len() == 0 {
+			continue
+		}
+
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   formatBotRole(botRole),
+			Value:  mentions.String(),
+			Inline: true,
+		})
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "Roles",
+		Description: "Loot admins can manage the ledger and sell, cancel, reassign or edit any item; overrides on items they don't sell are recorded. Once a role is mapped to seller, only sellers and loot admins can be assigned items. Everyone else is a member.",
+		Color:       0x00ffff,
+		Fields:      fields,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+}
+
+// botRoleChoices returns the slash command choices for every bot role, and for removing a role's mapping
+func botRoleChoices() []*discordgo.ApplicationCommandOptionChoice {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for rank := len(db.BotRoles) - 1; rank >= 0; rank-- {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  formatBotRole(db.BotRoles[rank]),
+			Value: db.BotRoles[rank],
+		})
+	}
+	return append(choices, &discordgo.ApplicationCommandOptionChoice{
+		Name:  "None (remove the mapping)",
+		Value: "none",
+	})
+}
+
+// formatBotRole names a bot role
+func formatBotRole(botRole string) string {
+	switch botRole {
+	case db.RoleLootAdmin:
+		return "Loot Admin"
+	case db.RoleSeller:
+		return "Seller"
+	default:
+		return "Member"
+	}
+}
+
+// remainderStrategyChoices returns the slash command choices for every remainder strategy
+func remainderStrategyChoices() []*discordgo.ApplicationCommandOptionChoice {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, strategy := range distribution.Strategies {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  strategy.Name(),
+			Value: string(strategy),
+		})
+	}
+	return choices
+}
+
+// formatRemainderStrategy describes a remainder strategy
+func formatRemainderStrategy(name string) string {
+	switch distribution.Strategy(name) {
+	case distribution.RoundRobin:
+		return "Round-robin: fewest past leftover orbs first"
+	case distribution.SeededRandom:
+		return "Seeded random: a random draw whose seed is stored with the sale"
+	default:
+		return "Seller first: the seller, then participants in the order they were added"
+	}
+}
+
+// formatRemainderAward renders a leftover orb for an item's details
+func formatRemainderAward(award db.RemainderAward) string {
+	line := fmt.Sprintf("<@%s> +1 (%s)", award.UserID, award.Reason)
+	if award.Reversed {
+		line = "~~" + line + "~~ sale reversed"
+	}
+	return line
+}
+
+// formatPercent renders a percentage without trailing zeros
+func formatPercent(pct float64) string {
+	return strconv.FormatFloat(pct, 'f', -1, 64)
+}
+
+// memberMention mentions a member, or names the guild bank pseudo-participant
+func memberMention(userID string) string {
+	if userID == db.BankUserID {
+		return "🏦 Guild Bank"
+	}
+	return fmt.Sprintf("<@%s>", userID)
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom ID prefixes of confirmation buttons
+const (
+	confirmPrefix = "confirm"
+	abortPrefix   = "abort"
+)
+
+// pendingActionTTL is how long a confirmation prompt stays valid
+const pendingActionTTL = 10 * time.Minute
+
+// pendingAction is a change waiting for its requester to press "Confirm"
+type pendingAction struct {
+	userID  string
+	expires time.Time
+	run     func(s *discordgo.Session, i *discordgo.InteractionCreate)
+}
+
+var (
+	pendingMu      sync.Mutex
+	pendingActions = make(map[string]*pendingAction)
+)
+
+// addPendingAction stores an action under a token until the user confirms it.
+// run receives the button interaction and must respond to it.
+func addPendingAction(token string, userID string, run func(s *discordgo.Session, i *discordgo.InteractionCreate)) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+
+	// Drop prompts nobody answered
+	now := time.Now()
+	for key, action := range pendingActions {
+		if now.After(action.expires) {
+			delete(pendingActions, key)
+		}
+	}
+
+	pendingActions[token] = &pendingAction{
+		userID:  userID,
+		expires: now.Add(pendingActionTTL),
+		run:     run,
+	}
+}
+
+// takePendingAction removes and returns a pending action if the user is allowed to answer it
+func takePendingAction(token string, userID string) (*pendingAction, string) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+
+	action, ok := pendingActions[token]
+	if !ok || time.Now().After(action.expires) {
+		delete(pendingActions, token)
+		return nil, "❌ This confirmation has expired. Please run the command again."
+	}
+
+	if action.userID != userID {
+		return nil, "❌ Only the member who ran the command can answer this."
+	}
+
+	delete(pendingActions, token)
+	return action, ""
+}
+
+// confirmationButtons returns the Confirm and Cancel buttons of a pending action
+func confirmationButtons(token string) *[]discordgo.MessageComponent {
+	return buttonRows([]discordgo.MessageComponent{
+		discordgo.Button{
+			Label:    "Confirm",
+			Style:    discordgo.SuccessButton,
+			CustomID: confirmPrefix + ":" + token,
+		},
+		discordgo.Button{
+			Label:    "Cancel",
+			Style:    discordgo.SecondaryButton,
+			CustomID: abortPrefix + ":" + token,
+		},
+	})
+}
+
+// handleConfirm runs a pending action once its requester confirms it
+func handleConfirm(s *discordgo.Session, i *discordgo.InteractionCreate, token string) {
+	action, problem := takePendingAction(token, i.Member.User.ID)
+	if action == nil {
+		respondEphemeral(s, i, problem)
+		return
+	}
+
+	action.run(s, i)
+}
+
+// handleAbort discards a pending action
+func handleAbort(s *discordgo.Session, i *discordgo.InteractionCreate, token string) {
+	action, problem := takePendingAction(token, i.Member.User.ID)
+	if action == nil {
+		respondEphemeral(s, i, problem)
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    "Cancelled, nothing was changed.",
+			Embeds:     []*discordgo.MessageEmbed{},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Printf("[Confirm] Error updating cancelled message: %v", err)
+	}
+}
+
+// respondUpdate replaces the message a button belongs to, removing its buttons
+func respondUpdate(s *discordgo.Session, i *discordgo.InteractionCreate, content string, embed *discordgo.MessageEmbed) {
+	embeds := []*discordgo.MessageEmbed{}
+	if embed != nil {
+		embeds = append(embeds, embed)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Embeds:     embeds,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating message: %v", err)
+	}
+}
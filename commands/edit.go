@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/db"
+)
+
+// handleSlashEdit handles the /docteur edit command by asking for confirmation of a diff
+func handleSlashEdit(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Edit] Processing edit request from user %s", i.Member.User.Username)
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	itemID, err := strconv.ParseInt(optionMap["item"].StringValue(), 10, 64)
+	if err != nil {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Invalid item ID. Please provide a valid number.",
+			},
+		})
+		return
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	item, err := db.GetItem(i.GuildID, itemID)
+	if err != nil {
+		log.Printf("Error getting item: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get item: " + err.Error()),
+		})
+		return
+	}
+
+	if item.Status != "assigned" {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Only items that haven't been sold can be edited."),
+		})
+		return
+	}
+
+	allowed, override := canActOnItem(i, item)
+	if !allowed {
+		log.Printf("[Edit] Rejected: User %s is not the seller of item #%d", i.Member.User.Username, itemID)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Only the seller or a loot admin can edit this item."),
+		})
+		return
+	}
+
+	// Collect the requested changes and describe them as a diff
+	var edit db.ItemEdit
+	var diff []*discordgo.MessageEmbedField
+	var unmatched *db.NameMatch
+
+	if nameOpt, ok := optionMap["name"]; ok && strings.TrimSpace(nameOpt.StringValue()) != "" {
+		match := resolveItemName(i.GuildID, nameOpt.StringValue(), false)
+		if match.Name != item.Name {
+			edit.Name = &match.Name
+			value := fmt.Sprintf("~~%s~~ → **%s**", item.Name, match.Name)
+			if !match.Matched {
+				unmatched = match
+				value += "\n⚠️ " + formatUnmatchedName(match)
+			}
+			diff = append(diff, &discordgo.MessageEmbedField{
+				Name:   "Name",
+				Value:  value,
+				Inline: false,
+			})
+		}
+	}
+
+	if quantityOpt, ok := optionMap["quantity"]; ok {
+		quantity := quantityOpt.IntValue()
+		if quantity <= 0 {
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("❌ The quantity must be greater than zero."),
+			})
+			return
+		}
+		if quantity != item.Quantity {
+			edit.Quantity = &quantity
+			diff = append(diff, &discordgo.MessageEmbedField{
+				Name:   "Quantity",
+				Value:  fmt.Sprintf("~~%d items~~ → **%d items**", item.Quantity, quantity),
+				Inline: false,
+			})
+		}
+	}
+
+	if sellerOpt, ok := optionMap["seller"]; ok {
+		sellerID := sellerOpt.UserValue(nil).ID
+		if sellerID == s.State.User.ID {
+			log.Printf("[Edit] Rejected: Attempted to set bot as seller")
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("❌ Docteur Peste cannot be the seller of an item."),
+			})
+			return
+		}
+		if sellerID != item.AssignedTo {
+			canHold, err := canHoldItems(i.GuildID, resolvedMember(i, sellerID))
+			if err != nil {
+				log.Printf("Error checking seller role: %v", err)
+				s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+					Content: strPtr("❌ Failed to check the seller's role: " + err.Error()),
+				})
+				return
+			}
+			if !canHold {
+				log.Printf("[Edit] Rejected: User %s does not have the seller role", sellerID)
+				s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+					Content: strPtr(fmt.Sprintf("❌ <@%s> doesn't have a seller role on this server.", sellerID)),
+				})
+				return
+			}
+
+			edit.SellerID = &sellerID
+			diff = append(diff, &discordgo.MessageEmbedField{
+				Name:   "Seller",
+				Value:  fmt.Sprintf("<@%s> → <@%s>", item.AssignedTo, sellerID),
+				Inline: false,
+			})
+		}
+	}
+
+	if len(diff) == 0 {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("Nothing to change. Provide a new name, quantity or seller."),
+		})
+		return
+	}
+
+	// Wait for the requester to confirm before saving
+	addPendingAction(i.ID, i.Member.User.ID, func(s *discordgo.Session, ci *discordgo.InteractionCreate) {
+		applyItemEdit(s, ci, item, edit, diff, unmatched, override)
+	})
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Edit Item #%d: %s", item.ID, item.Name),
+		Description: "Please review the changes below and confirm.",
+		Color:       0xffff00,
+		Fields:      diff,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &[]*discordgo.MessageEmbed{embed},
+		Components: confirmationButtons(i.ID),
+	})
+}
+
+// applyItemEdit saves a confirmed edit and replaces the confirmation prompt with the result.
+// Unmatched is the new name when it isn't in the item catalog, and is flagged once the edit is saved.
+// Override is set when a loot admin edits an item they don't sell, which is recorded.
+func applyItemEdit(s *discordgo.Session, i *discordgo.InteractionCreate, item *db.Item, edit db.ItemEdit, diff []*discordgo.MessageEmbedField, unmatched *db.NameMatch, override bool) {
+	action := "edit"
+	if edit.SellerID != nil {
+		action = "reassign"
+	}
+	var changed []string
+	for _, field := range diff {
+		changed = append(changed, strings.ToLower(field.Name))
+	}
+
+	actor := overrideActor(i, override, action, "changed "+strings.Join(changed, ", "))
+	if err := db.EditItem(i.GuildID, item.ID, edit, actor); err != nil {
+		log.Printf("[Edit] Failed to edit item #%d: %v", item.ID, err)
+		respondUpdate(s, i, "❌ Failed to edit item: "+err.Error(), nil)
+		return
+	}
+
+	if unmatched != nil {
+		if err := db.FlagUnmatchedName(i.GuildID, unmatched.Name, unmatched.Suggestion); err != nil {
+			log.Printf("[Edit] Warning: Failed to flag unmatched name %q: %v", unmatched.Name, err)
+		}
+	}
+
+	logOverride(i, actor, item.ID)
+
+	name := item.Name
+	if edit.Name != nil {
+		name = *edit.Name
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Item Updated",
+		Description: fmt.Sprintf("Item **%s** (ID: **%d**) has been updated by %s", name, item.ID, i.Member.User.Mention()),
+		Color:       0x00ff00,
+		Fields:      diff,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+	respondUpdate(s, i, "", embed)
+
+	log.Printf("[Edit] Successfully edited item #%d", item.ID)
+}
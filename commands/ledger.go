@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/currency"
+	"github.com/intinig/dr-peste/db"
+)
+
+// ledgerIssuesListed is how many problems of each kind /docteur admin rebuild-ledger lists
+const ledgerIssuesListed = 15
+
+// handleSlashAdminRebuildLedger handles the /docteur admin rebuild-ledger command by replaying the journal
+// and reporting every balance that disagrees with the profit history or the bank ledger
+func handleSlashAdminRebuildLedger(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	log.Printf("[Admin] Processing ledger rebuild from user %s", i.Member.User.Username)
+
+	if !isLootAdmin(i) {
+		log.Printf("[Admin] Rejected: User %s is not a loot admin", i.Member.User.Username)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Only loot admins can rebuild the ledger.",
+			},
+		})
+		return
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	check, err := db.RebuildLedger(i.GuildID)
+	if err != nil {
+		log.Printf("[Admin] Failed to rebuild ledger: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to rebuild the ledger: " + err.Error()),
+		})
+		return
+	}
+
+	description := fmt.Sprintf("Replayed %d journal entries across %d accounts.", check.Entries, check.Accounts)
+	color := 0x00ff00
+	if check.Consistent() {
+		description += " Every entry balances, and every balance matches the profit history, the shares sellers hold and the bank ledger."
+	} else {
+		color = 0xffa500
+	}
+
+	var fields []*discordgo.MessageEmbedField
+	if len(check.Unbalanced) > 0 {
+		var ids []string
+		for idx, entryID := range check.Unbalanced {
+			if idx >= ledgerIssuesListed {
+				ids = append(ids, fmt.Sprintf("…and %d more", len(check.Unbalanced)-ledgerIssuesListed))
+				break
+			}
+			ids = append(ids, fmt.Sprintf("#%d", entryID))
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "⚠️ Unbalanced Entries",
+			Value:  truncateField(strings.Join(ids, ", ")),
+			Inline: false,
+		})
+	}
+	if len(check.Mismatches) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "⚠️ Disagrees With Profit History",
+			Value:  formatBalanceMismatches(check.Mismatches, "journal %s, recorded %s"),
+			Inline: false,
+		})
+	}
+	if len(check.Corrected) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "🔧 Corrected Balances",
+			Value:  formatBalanceMismatches(check.Corrected, "%s, was %s"),
+			Inline: false,
+		})
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "📒 Ledger Rebuilt",
+		Description: description,
+		Color:       color,
+		Fields:      fields,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Admin] Rebuilt ledger: %d entries, %d unbalanced, %d mismatches, %d corrected",
+		check.Entries, len(check.Unbalanced), len(check.Mismatches), len(check.Corrected))
+}
+
+// formatBalanceMismatches lists mismatched accounts, one per line, with the journal and other balance filled into format
+func formatBalanceMismatches(mismatches []db.BalanceMismatch, format string) string {
+	var list strings.Builder
+	for idx, mismatch := range mismatches {
+		if idx >= ledgerIssuesListed {
+			list.WriteString(fmt.Sprintf("…and %d more\n", len(mismatches)-ledgerIssuesListed))
+			break
+		}
+		list.WriteString(fmt.Sprintf("%s: %s\n", formatAccount(mismatch.Account),
+			fmt.Sprintf(format, currency.FormatBase(mismatch.Journal), currency.FormatBase(mismatch.Other))))
+	}
+	return truncateField(list.String())
+}
+
+// formatAccount renders a ledger account, mentioning the member it belongs to
+func formatAccount(account db.Account) string {
+	switch account.Kind {
+	case db.AccountUser:
+		return fmt.Sprintf("<@%s>", account.ID)
+	case db.AccountEscrow:
+		return fmt.Sprintf("held by <@%s>", account.ID)
+	case db.AccountBank:
+		return "🏦 Guild Bank"
+	case db.AccountExternal:
+		return "Outside the guild"
+	}
+	return account.String()
+}
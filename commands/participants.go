@@ -0,0 +1,233 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/db"
+)
+
+// handleSlashParticipants handles the /docteur participants command group
+func handleSlashParticipants(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(data.Options) == 0 {
+		return
+	}
+
+	subCommand := data.Options[0]
+	switch subCommand.Name {
+	case "add", "remove", "weight":
+		handleSlashParticipantsChange(s, i, subCommand)
+	}
+}
+
+// handleSlashParticipantsChange handles the /docteur participants add, remove and weight commands
+func handleSlashParticipantsChange(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Participants] Processing %s request from user %s", data.Name, i.Member.User.Username)
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	itemID, err := strconv.ParseInt(optionMap["item"].StringValue(), 10, 64)
+	if err != nil {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Invalid item ID. Please provide a valid number.",
+			},
+		})
+		return
+	}
+
+	// New participants get a weight of 1 unless given one
+	weight := 1.0
+	if weightOpt, ok := optionMap["weight"]; ok {
+		weight = weightOpt.FloatValue()
+	}
+	if weight <= 0 {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ The weight must be greater than zero.",
+			},
+		})
+		return
+	}
+
+	user := optionMap["user"].UserValue(nil)
+	if data.Name == "add" && user.ID == s.State.User.ID {
+		log.Printf("[Participants] Rejected: Attempted to add bot as participant")
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Docteur Peste cannot be a participant.",
+			},
+		})
+		return
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	item, err := db.GetItem(i.GuildID, itemID)
+	if err != nil {
+		log.Printf("Error getting item: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get item: " + err.Error()),
+		})
+		return
+	}
+
+	allowed, override := canActOnItem(i, item)
+	if !allowed {
+		log.Printf("[Participants] Rejected: User %s is not the seller of item #%d", i.Member.User.Username, itemID)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Only the seller or a loot admin can change the participants of this item."),
+		})
+		return
+	}
+
+	actor := overrideActor(i, override, "participants "+data.Name, fmt.Sprintf("<@%s>", user.ID))
+
+	var title, description string
+	var color int
+	switch data.Name {
+	case "add":
+		err = db.AddParticipant(i.GuildID, item.ID, user.ID, weight, actor)
+		title = "Participant Added"
+		description = fmt.Sprintf("%s now shares in **%s** (ID: **%d**)", user.Mention(), item.Name, item.ID)
+		color = 0x00ff00
+	case "remove":
+		err = db.RemoveParticipant(i.GuildID, item.ID, user.ID, actor)
+		title = "Participant Removed"
+		description = fmt.Sprintf("%s no longer shares in **%s** (ID: **%d**)", user.Mention(), item.Name, item.ID)
+		color = 0xffa500
+	case "weight":
+		err = db.SetParticipantWeight(i.GuildID, item.ID, user.ID, weight, actor)
+		title = "Participant Weight Changed"
+		description = fmt.Sprintf("%s now has a share weight of **%s** in **%s** (ID: **%d**)",
+			user.Mention(), strconv.FormatFloat(weight, 'f', -1, 64), item.Name, item.ID)
+		color = 0x00ffff
+	}
+	if err != nil {
+		log.Printf("[Participants] Failed to %s participant on item #%d: %v", data.Name, item.ID, err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to update participants: " + err.Error()),
+		})
+		return
+	}
+
+	logOverride(i, actor, item.ID)
+
+	// Reload the item to show the new participant list
+	item, err = db.GetItem(i.GuildID, item.ID)
+	if err != nil {
+		log.Printf("Error getting item: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Participants were updated, but the item could not be reloaded: " + err.Error()),
+		})
+		return
+	}
+
+	var mentions []string
+	for _, p := range item.Participants {
+		mentions = append(mentions, fmt.Sprintf("<@%s>%s", p.UserID, formatWeight(p.Weight)))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description,
+		Color:       color,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   fmt.Sprintf("Participants (%d)", len(item.Participants)),
+				Value:  strings.Join(mentions, ", "),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Participants] Successfully ran %s on item #%d", data.Name, item.ID)
+}
+
+// mentionRegex matches a single Discord user mention
+var mentionRegex = regexp.MustCompile(`^<@!?\d+>$`)
+
+// parseMentions reads the user IDs of mentions separated by commas or spaces, e.g. "@user1 @user2, @user3".
+// The bot is skipped. Users already in seen are rejected as duplicates, and the users read are added to it.
+func parseMentions(str string, botID string, seen map[string]bool) ([]string, error) {
+	var userIDs []string
+	for _, mention := range strings.FieldsFunc(str, func(r rune) bool { return r == ',' || r == ' ' }) {
+		// Check for concatenated mentions (e.g., "@user1@user2")
+		if strings.Count(mention, "<@") > 1 {
+			return nil, fmt.Errorf("invalid mention format. Please separate user mentions with spaces or commas (e.g., @user1 @user2 or @user1, @user2)")
+		}
+		if !mentionRegex.MatchString(mention) {
+			return nil, fmt.Errorf("invalid mention format. Please use proper Discord mentions (e.g., @user1 @user2) with spaces between users")
+		}
+
+		userID := strings.TrimPrefix(mention, "<@")
+		userID = strings.TrimPrefix(userID, "!") // Handle nickname mentions
+		userID = strings.TrimSuffix(userID, ">")
+
+		// Skip if this is the bot
+		if userID == botID {
+			continue
+		}
+		if seen[userID] {
+			return nil, fmt.Errorf("user <@%s> cannot be listed multiple times. Each user can only be included once", userID)
+		}
+		seen[userID] = true
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
+}
+
+// weightRegex matches a single "@user=weight" pair
+var weightRegex = regexp.MustCompile(`<@!?(\d+)>\s*[=:]\s*(\d+(?:\.\d+)?)`)
+
+// parseWeights parses share weights written as "@user=2 @other=0.5", separated by spaces or commas
+func parseWeights(str string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, match := range weightRegex.FindAllStringSubmatch(str, -1) {
+		weight, err := strconv.ParseFloat(match[2], 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("weight of <@%s> must be a number greater than zero", match[1])
+		}
+		if _, ok := weights[match[1]]; ok {
+			return nil, fmt.Errorf("user <@%s> has more than one weight", match[1])
+		}
+		weights[match[1]] = weight
+	}
+
+	// Anything besides the pairs and their separators is a typo
+	leftover := strings.Trim(weightRegex.ReplaceAllString(str, ""), " ,")
+	if leftover != "" || len(weights) == 0 {
+		return nil, fmt.Errorf("invalid weights format. Use mentions with a weight, e.g. @carrier=2 @latejoiner=0.5")
+	}
+
+	return weights, nil
+}
+
+// formatWeight renders a participant's share weight next to their mention, or nothing for the default weight
+func formatWeight(weight float64) string {
+	if weight == 1 {
+		return ""
+	}
+	return fmt.Sprintf(" (×%s)", strconv.FormatFloat(weight, 'f', -1, 64))
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/db"
+	"github.com/intinig/dr-peste/poeitem"
+)
+
+// Custom IDs of the item text modal
+const (
+	pastePrefix    = "paste"
+	pasteTextInput = "item_text"
+)
+
+// showPasteModal asks for the in-game text of an item and calls add with the parsed item.
+// add receives the modal submission, which it must acknowledge.
+func showPasteModal(s *discordgo.Session, i *discordgo.InteractionCreate, add func(s *discordgo.Session, mi *discordgo.InteractionCreate, item *poeitem.Item, details *db.ItemDetails)) {
+	addPendingAction(i.ID, i.Member.User.ID, func(s *discordgo.Session, mi *discordgo.InteractionCreate) {
+		text := modalValue(mi.ModalSubmitData(), pasteTextInput)
+		item, err := poeitem.Parse(text)
+		if err != nil {
+			log.Printf("[Add] Rejected: Failed to parse pasted item: %v", err)
+			s.InteractionRespond(mi.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "❌ Couldn't read the item text: " + err.Error(),
+				},
+			})
+			return
+		}
+
+		// Acknowledge the interaction
+		s.InteractionRespond(mi.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		})
+
+		add(s, mi, item, &db.ItemDetails{
+			Class:     item.Class,
+			Rarity:    item.Rarity,
+			BaseType:  item.BaseType,
+			ItemLevel: int64(item.ItemLevel),
+			Corrupted: item.Corrupted,
+			Mods:      item.Mods,
+			RawText:   text,
+		})
+	})
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: pastePrefix + ":" + i.ID,
+			Title:    "Paste Item",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    pasteTextInput,
+							Label:       "Item text",
+							Style:       discordgo.TextInputParagraph,
+							Placeholder: "Hover the item in game, press Ctrl+C and paste it here",
+							Required:    true,
+							MaxLength:   4000,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("[Add] Error showing paste modal: %v", err)
+	}
+}
+
+// handleModalSubmit handles modals submitted by members.
+// Custom IDs have the form "<action>:<argument>".
+func handleModalSubmit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// Ledgers are per guild, so modals only work inside a server
+	if i.GuildID == "" || i.Member == nil {
+		return
+	}
+
+	customID := i.ModalSubmitData().CustomID
+	action, argument, _ := strings.Cut(customID, ":")
+	log.Printf("[Modal] Processing %s from user %s", action, i.Member.User.Username)
+
+	switch action {
+	case pastePrefix:
+		pending, problem := takePendingAction(argument, i.Member.User.ID)
+		if pending == nil {
+			respondEphemeral(s, i, problem)
+			return
+		}
+		pending.run(s, i)
+	default:
+		log.Printf("[Modal] Unknown modal action: %s", customID)
+	}
+}
+
+// modalValue returns the value of a text input of a submitted modal
+func modalValue(data discordgo.ModalSubmitInteractionData, customID string) string {
+	for _, component := range data.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, rowComponent := range row.Components {
+			if input, ok := rowComponent.(*discordgo.TextInput); ok && input.CustomID == customID {
+				return input.Value
+			}
+		}
+	}
+	return ""
+}
+
+// formatItemDetails renders what was read from a pasted item
+func formatItemDetails(details *db.ItemDetails) string {
+	summary := []string{details.Rarity}
+	if details.BaseType != "" {
+		summary = append(summary, details.BaseType)
+	}
+	if details.ItemLevel > 0 {
+		summary = append(summary, fmt.Sprintf("ilvl %d", details.ItemLevel))
+	}
+	if details.Corrupted {
+		summary = append(summary, "Corrupted")
+	}
+
+	value := strings.Join(summary, " • ")
+	for _, mod := range details.Mods {
+		// Embed fields are limited to 1024 characters
+		if len(value)+len(mod)+1 > 1000 {
+			value += "\n…"
+			break
+		}
+		value += "\n" + mod
+	}
+	return value
+}
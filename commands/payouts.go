@@ -0,0 +1,274 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/currency"
+	"github.com/intinig/dr-peste/db"
+)
+
+// shareConfirmPrefix prefixes the custom ID of "confirm receipt" buttons
+const shareConfirmPrefix = "share_confirm"
+
+// handleSlashPayout handles the /docteur payout command
+func handleSlashPayout(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Payout] Processing payout request from user %s", i.Member.User.Username)
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	// Get item ID from the string value
+	itemID, err := strconv.ParseInt(optionMap["item"].StringValue(), 10, 64)
+	if err != nil {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Invalid item ID. Please provide a valid number.",
+			},
+		})
+		return
+	}
+
+	// Optionally only pay out a single participant
+	var recipientID string
+	if userOpt, ok := optionMap["user"]; ok {
+		recipientID = userOpt.UserValue(nil).ID
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	item, err := db.GetItem(i.GuildID, itemID)
+	if err != nil {
+		log.Printf("Error getting item: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get item: " + err.Error()),
+		})
+		return
+	}
+
+	if item.SoldQuantity == 0 {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ This item has not been sold yet."),
+		})
+		return
+	}
+
+	// Only whoever sold a stack holds its orbs, so only they can hand its shares over. That may no longer
+	// be the item's seller once it was reassigned, so only the caller's own shares are marked.
+	shares, err := db.MarkSharesPaid(i.GuildID, itemID, i.Member.User.ID, recipientID, actorOf(i))
+	if err != nil {
+		log.Printf("[Payout] Failed to mark shares as paid: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to record payout: " + err.Error()),
+		})
+		return
+	}
+
+	if len(shares) == 0 {
+		log.Printf("[Payout] User %s holds no unpaid shares of item #%d", i.Member.User.Username, itemID)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("There are no unpaid shares of this item for you to mark. Only whoever sold a stack can mark its shares as paid."),
+		})
+		return
+	}
+
+	// Build the list of delivered shares and a confirmation button for each recipient
+	var sharesValue strings.Builder
+	var buttons []discordgo.MessageComponent
+	for _, share := range shares {
+		sharesValue.WriteString(fmt.Sprintf("<@%s>: %s\n", share.UserID, currency.FormatBase(share.Amount)))
+
+		// Discord allows at most 25 buttons per message
+		if len(buttons) < 25 {
+			buttons = append(buttons, discordgo.Button{
+				Label:    fmt.Sprintf("Confirm receipt: %s", userDisplayName(s, share.UserID)),
+				Style:    discordgo.SuccessButton,
+				CustomID: fmt.Sprintf("%s:%d", shareConfirmPrefix, share.ID),
+			})
+		}
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Payout Recorded",
+		Description: fmt.Sprintf("<@%s> handed over shares of **%s** (ID: **%d**). Recipients, please confirm you received your orbs.", i.Member.User.ID, item.Name, itemID),
+		Color:       0x00ff00,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Paid Shares",
+				Value:  sharesValue.String(),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &[]*discordgo.MessageEmbed{embed},
+		Components: buttonRows(buttons),
+	})
+
+	log.Printf("[Payout] Marked %d shares of item #%d as paid", len(shares), itemID)
+}
+
+// handleShareConfirm handles a participant pressing a "confirm receipt" button
+func handleShareConfirm(s *discordgo.Session, i *discordgo.InteractionCreate, shareIDStr string) {
+	shareID, err := strconv.ParseInt(shareIDStr, 10, 64)
+	if err != nil {
+		respondEphemeral(s, i, "❌ Invalid share.")
+		return
+	}
+
+	if err := db.ConfirmShare(i.GuildID, shareID, actorOf(i)); err != nil {
+		log.Printf("[Payout] User %s could not confirm share #%d: %v", i.Member.User.Username, shareID, err)
+		respondEphemeral(s, i, "❌ "+err.Error())
+		return
+	}
+
+	// Disable the button so the confirmation is visible to everyone
+	customID := i.MessageComponentData().CustomID
+	components := disableButton(i.Message.Components, customID, fmt.Sprintf("Received: %s", i.Member.User.Username))
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    i.Message.Content,
+			Embeds:     i.Message.Embeds,
+			Components: components,
+		},
+	})
+	if err != nil {
+		log.Printf("[Payout] Error updating payout message: %v", err)
+	}
+
+	log.Printf("[Payout] User %s confirmed receipt of share #%d", i.Member.User.Username, shareID)
+}
+
+// handleSlashOwed handles the /docteur owed command
+func handleSlashOwed(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Owed] Processing owed request from user %s", i.Member.User.Username)
+
+	// Optionally only show debts involving one member
+	var filterUserID string
+	for _, opt := range data.Options {
+		if opt.Name == "user" {
+			filterUserID = opt.UserValue(nil).ID
+		}
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	debts, err := db.GetOutstandingDebts(i.GuildID)
+	if err != nil {
+		log.Printf("[Owed] Failed to get outstanding debts: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get outstanding debts: " + err.Error()),
+		})
+		return
+	}
+
+	// Combine both directions of each pair of members
+	type pairDebt struct {
+		Debtor   string
+		Creditor string
+		Owed     int64 // debtor owes creditor
+		OwedBack int64 // creditor owes debtor
+	}
+	pairs := make(map[[2]string]*pairDebt)
+	for _, debt := range debts {
+		if filterUserID != "" && debt.From != filterUserID && debt.To != filterUserID {
+			continue
+		}
+
+		key := [2]string{debt.From, debt.To}
+		if key[0] > key[1] {
+			key[0], key[1] = key[1], key[0]
+		}
+
+		pair, exists := pairs[key]
+		if !exists {
+			pair = &pairDebt{Debtor: debt.From, Creditor: debt.To}
+			pairs[key] = pair
+		}
+
+		if debt.From == pair.Debtor {
+			pair.Owed += debt.Amount
+		} else {
+			pair.OwedBack += debt.Amount
+		}
+	}
+
+	// Express every pair as a single net debt
+	var netDebts []pairDebt
+	for _, pair := range pairs {
+		if pair.Owed < pair.OwedBack {
+			pair.Debtor, pair.Creditor = pair.Creditor, pair.Debtor
+			pair.Owed, pair.OwedBack = pair.OwedBack, pair.Owed
+		}
+		if pair.Owed != pair.OwedBack {
+			netDebts = append(netDebts, *pair)
+		}
+	}
+
+	if len(netDebts) == 0 {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("✅ Nobody owes anybody anything."),
+		})
+		return
+	}
+
+	// Sort debts by net amount (descending)
+	sort.Slice(netDebts, func(a, b int) bool {
+		return netDebts[a].Owed-netDebts[a].OwedBack > netDebts[b].Owed-netDebts[b].OwedBack
+	})
+
+	var debtsValue strings.Builder
+	for _, debt := range netDebts {
+		debtsValue.WriteString(fmt.Sprintf("<@%s> → <@%s>: **%s**", debt.Debtor, debt.Creditor, currency.FormatBase(debt.Owed-debt.OwedBack)))
+		if debt.OwedBack > 0 {
+			debtsValue.WriteString(fmt.Sprintf(" (%d owed, %d owed back)", debt.Owed, debt.OwedBack))
+		}
+		debtsValue.WriteString("\n")
+	}
+
+	description := "Unpaid shares between each pair of members"
+	if filterUserID != "" {
+		description = fmt.Sprintf("Unpaid shares involving <@%s>", filterUserID)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Outstanding Debts",
+		Description: description,
+		Color:       0xffa500,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Who Owes Whom",
+				Value:  debtsValue.String(),
+				Inline: false,
+			},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Sellers can mark shares as delivered with /docteur payout",
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Owed] Successfully displayed %d outstanding debts", len(netDebts))
+}
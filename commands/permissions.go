@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/db"
+)
+
+// isGuildAdmin reports whether the member invoking an interaction can manage the server
+func isGuildAdmin(i *discordgo.InteractionCreate) bool {
+	if i.Member == nil {
+		return false
+	}
+	return i.Member.Permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageServer) != 0
+}
+
+// memberRole returns the bot role of a member: the most trusted role mapped to any of their Discord roles,
+// or member if none is. Server admins are always loot admins so a server can't lock itself out.
+func memberRole(guildID string, member *discordgo.Member, roles []db.GuildRole) string {
+	if member == nil {
+		return db.RoleMember
+	}
+	if member.Permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageServer) != 0 {
+		return db.RoleLootAdmin
+	}
+
+	// Roles are sorted from the most trusted, so the first match wins
+	for _, role := range roles {
+		// The @everyone role shares the guild's ID and isn't listed in the member's roles
+		if role.RoleID == guildID {
+			return role.BotRole
+		}
+		for _, roleID := range member.Roles {
+			if roleID == role.RoleID {
+				return role.BotRole
+			}
+		}
+	}
+	return db.RoleMember
+}
+
+// isLootAdmin reports whether the member invoking an interaction can manage the ledger and act on any item
+func isLootAdmin(i *discordgo.InteractionCreate) bool {
+	if isGuildAdmin(i) {
+		return true
+	}
+
+	roles, err := db.GetGuildRoles(i.GuildID)
+	if err != nil {
+		log.Printf("Error getting guild roles: %v", err)
+		return false
+	}
+	return memberRole(i.GuildID, i.Member, roles) == db.RoleLootAdmin
+}
+
+// canActOnItem reports whether the member invoking an interaction may act on an item as its seller would.
+// Loot admins may act on any item; override is true when they act on an item they don't sell.
+func canActOnItem(i *discordgo.InteractionCreate, item *db.Item) (allowed bool, override bool) {
+	if item.AssignedTo == i.Member.User.ID {
+		return true, false
+	}
+	if isLootAdmin(i) {
+		return true, true
+	}
+	return false, false
+}
+
+// canHoldItems reports whether a member can be assigned items to sell. Anyone can until the server maps a
+// Discord role to the seller role; from then on only sellers and loot admins can.
+func canHoldItems(guildID string, member *discordgo.Member) (bool, error) {
+	roles, err := db.GetGuildRoles(guildID)
+	if err != nil {
+		return false, err
+	}
+
+	restricted := false
+	for _, role := range roles {
+		if role.BotRole == db.RoleSeller {
+			restricted = true
+			break
+		}
+	}
+	if !restricted {
+		return true, nil
+	}
+
+	return db.RoleRank(memberRole(guildID, member, roles)) >= db.RoleRank(db.RoleSeller), nil
+}
+
+// resolvedMember returns the member behind a user option of a command, as Discord resolved it
+func resolvedMember(i *discordgo.InteractionCreate, userID string) *discordgo.Member {
+	if userID == i.Member.User.ID {
+		return i.Member
+	}
+
+	resolved := i.ApplicationCommandData().Resolved
+	if resolved == nil {
+		return nil
+	}
+	return resolved.Members[userID]
+}
+
+// overrideActor returns the member behind an interaction for the audit log. When override is set, they are a
+// loot admin acting on something they don't own, and the change they make records it as an admin override.
+func overrideActor(i *discordgo.InteractionCreate, override bool, action string, detail string) db.Actor {
+	actor := actorOf(i)
+	if override {
+		actor.Override = &db.Override{Action: action, Detail: detail}
+	}
+	return actor
+}
+
+// logOverride logs the admin override an actor made, if any, once the change that recorded it is saved
+func logOverride(i *discordgo.InteractionCreate, actor db.Actor, itemID int64) {
+	if actor.Override == nil {
+		return
+	}
+	log.Printf("[Override] %s used loot admin rights to %s (item #%d): %s", i.Member.User.Username, actor.Override.Action, itemID, actor.Override.Detail)
+}
@@ -0,0 +1,274 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/currency"
+	"github.com/intinig/dr-peste/db"
+	"github.com/intinig/dr-peste/prices"
+)
+
+// Limits of the /docteur price embed
+const (
+	priceChartLimit   = 20 // observations drawn in the sparkline
+	priceHistoryLimit = 10 // observations listed one by one
+)
+
+// handleSlashPrice handles the /docteur price command
+func handleSlashPrice(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Price] Processing price request from user %s", i.Member.User.Username)
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	itemName := strings.TrimSpace(optionMap["item"].StringValue())
+
+	var check float64
+	if checkOpt, ok := optionMap["check"]; ok {
+		check = checkOpt.FloatValue()
+		if check <= 0 {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "❌ The price must be greater than zero.",
+				},
+			})
+			return
+		}
+	}
+
+	// Get the price check currency, defaulting to the base currency
+	checkCurrency := currency.Base
+	if currencyOpt, ok := optionMap["currency"]; ok {
+		var err error
+		checkCurrency, err = currency.Parse(currencyOpt.StringValue())
+		if err != nil {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "❌ " + err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	// Look the item up under its catalog name; unknown names are only flagged when a price is recorded for them
+	itemName = resolveItemName(i.GuildID, itemName, check > 0).Name
+
+	// Record the price check first so it shows up in the history
+	var recorded string
+	if check > 0 {
+		unitPrice := check
+		if !checkCurrency.IsBase() {
+			rate, err := db.GetCurrencyRate(i.GuildID, string(checkCurrency))
+			if err != nil {
+				log.Printf("[Price] Failed to get conversion rate: %v", err)
+				s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+					Content: strPtr("❌ Failed to get conversion rate: " + err.Error()),
+				})
+				return
+			}
+			if rate == nil {
+				s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+					Content: strPtr(fmt.Sprintf("❌ No conversion rate is set for %s. Ask an admin to run `/docteur rates set`.", checkCurrency.Name())),
+				})
+				return
+			}
+			unitPrice = check * rate.Rate
+		}
+
+		if _, err := db.RecordPriceCheck(i.GuildID, itemName, unitPrice, actorOf(i)); err != nil {
+			log.Printf("[Price] Failed to record price check: %v", err)
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("❌ Failed to record price check: " + err.Error()),
+			})
+			return
+		}
+
+		recorded = fmt.Sprintf("Recorded %s's price check of %s per unit.\n", i.Member.User.Mention(), formatUnitPrice(unitPrice))
+		if !checkCurrency.IsBase() {
+			recorded = fmt.Sprintf("Recorded %s's price check of %s %s (%s) per unit.\n",
+				i.Member.User.Mention(), strconv.FormatFloat(check, 'f', -1, 64), checkCurrency.Name(), formatUnitPrice(unitPrice))
+		}
+		log.Printf("[Price] Recorded price check of %s for %s", formatUnitPrice(unitPrice), itemName)
+	}
+
+	observations, err := db.GetPriceObservations(i.GuildID, itemName, priceChartLimit)
+	if err != nil {
+		log.Printf("[Price] Failed to get price observations: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get price history: " + err.Error()),
+		})
+		return
+	}
+
+	if len(observations) == 0 {
+		handleSlashPriceMarket(s, i, itemName)
+		return
+	}
+
+	points := db.PricePoints(observations)
+	summary := prices.Summarize(points)
+	estimate, _ := prices.EstimateAt(points, time.Now())
+
+	// Draw the chart oldest to newest
+	var chartPrices []float64
+	for idx := len(observations) - 1; idx >= 0; idx-- {
+		chartPrices = append(chartPrices, observations[idx].UnitPrice)
+	}
+
+	var history strings.Builder
+	for idx, o := range observations {
+		if idx >= priceHistoryLimit {
+			break
+		}
+		history.WriteString(formatPriceObservation(o) + "\n")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("💹 Price of %s", itemName),
+		Description: recorded + fmt.Sprintf("Estimated at **~%s** per unit (%s)", formatUnitPrice(estimate.UnitPrice), formatEstimateBasis(estimate)),
+		Color:       0x00ffff,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Min",
+				Value:  formatUnitPrice(summary.Min),
+				Inline: true,
+			},
+			{
+				Name:   "Median",
+				Value:  formatUnitPrice(summary.Median),
+				Inline: true,
+			},
+			{
+				Name:   "Max",
+				Value:  formatUnitPrice(summary.Max),
+				Inline: true,
+			},
+			{
+				Name:   "Trend",
+				Value:  formatTrend(summary, len(observations)),
+				Inline: true,
+			},
+			{
+				Name:   fmt.Sprintf("Chart (last %d, oldest first)", len(chartPrices)),
+				Value:  fmt.Sprintf("`%s`", prices.Sparkline(chartPrices)),
+				Inline: false,
+			},
+			{
+				Name:   fmt.Sprintf("Last %d Prices", min(len(observations), priceHistoryLimit)),
+				Value:  history.String(),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+}
+
+// handleSlashPriceMarket answers /docteur price for an item the guild has no prices for, using the market snapshot
+func handleSlashPriceMarket(s *discordgo.Session, i *discordgo.InteractionCreate, itemName string) {
+	market, err := db.GetMarketPrice(itemName)
+	if err != nil {
+		log.Printf("[Price] Failed to get market price: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get market price: " + err.Error()),
+		})
+		return
+	}
+
+	if market == nil {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr(fmt.Sprintf("No prices have been recorded for **%s** yet. Sell one, or record a price check with the `check` option.", itemName)),
+		})
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("💹 Price of %s", market.ItemName),
+		Description: fmt.Sprintf("The guild has no prices for this item yet. The market price is **%s** per unit.", formatUnitPrice(market.UnitPrice)),
+		Color:       0x00ffff,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Market snapshot imported " + market.ImportedAt.Format("Jan 02, 2006 15:04"),
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+}
+
+// formatEstimate renders the estimated value of a number of units with what it is based on,
+// e.g. "~45 Exalted Orbs (7 prices, last 3 days)"
+func formatEstimate(estimate prices.Estimate, quantity int64) string {
+	return fmt.Sprintf("~%s (%s)", currency.FormatBase(estimate.Value(quantity)), formatEstimateBasis(estimate))
+}
+
+// formatEstimateBasis describes how many observations an estimate is based on and how far back they go
+func formatEstimateBasis(estimate prices.Estimate) string {
+	if estimate.Market {
+		return "market price from " + estimate.Newest.Format("Jan 02")
+	}
+
+	samples := fmt.Sprintf("%d prices", estimate.Samples)
+	if estimate.Samples == 1 {
+		samples = "1 price"
+	}
+
+	days := int(math.Ceil(time.Since(estimate.Oldest).Hours() / 24))
+	if days <= 1 {
+		return samples + ", last day"
+	}
+	return fmt.Sprintf("%s, last %d days", samples, days)
+}
+
+// formatUnitPrice renders a unit price in the base currency to one decimal place
+func formatUnitPrice(price float64) string {
+	return fmt.Sprintf("%s %s", strconv.FormatFloat(math.Round(price*10)/10, 'f', -1, 64), currency.Base.Name())
+}
+
+// formatTrend renders how the price moved between the older and newer observations
+func formatTrend(summary prices.Summary, samples int) string {
+	pct := math.Round(summary.Trend * 100)
+	switch {
+	case samples < 2:
+		return "Not enough prices yet"
+	case pct > 0:
+		return fmt.Sprintf("📈 +%s%%", strconv.FormatFloat(pct, 'f', -1, 64))
+	case pct < 0:
+		return fmt.Sprintf("📉 %s%%", strconv.FormatFloat(pct, 'f', -1, 64))
+	default:
+		return "➡️ Stable"
+	}
+}
+
+// formatPriceObservation renders a price observation for the price history
+func formatPriceObservation(o db.PriceObservation) string {
+	var source string
+	switch o.Source {
+	case db.PriceFromSale:
+		source = fmt.Sprintf("sale #%d of %d by <@%s>", o.SaleID, o.Quantity, o.ObservedBy)
+	default:
+		source = fmt.Sprintf("price check by <@%s>", o.ObservedBy)
+	}
+	return fmt.Sprintf("%s • %s on %s", formatUnitPrice(o.UnitPrice), source, o.CreatedAt.Format("Jan 02"))
+}
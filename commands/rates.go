@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/currency"
+	"github.com/intinig/dr-peste/db"
+)
+
+// currencyChoices returns the slash command choices for every supported currency
+func currencyChoices() []*discordgo.ApplicationCommandOptionChoice {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, c := range currency.All {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  c.Name(),
+			Value: string(c),
+		})
+	}
+	return choices
+}
+
+// formatSale renders a sale amount, including the original currency if it was converted
+func formatSale(item db.Item) string {
+	return formatSaleAmount(item.SaleAmount, item.SaleCurrency, item.SaleOriginal)
+}
+
+// formatSaleAmount renders a sale amount in the base currency, with the original amount if it was sold in another currency
+func formatSaleAmount(amount int64, saleCurrencyName string, originalAmount int64) string {
+	saleCurrency, err := currency.Parse(saleCurrencyName)
+	if err != nil || saleCurrency.IsBase() {
+		return currency.FormatBase(amount)
+	}
+	return fmt.Sprintf("%s (%s)", currency.FormatBase(amount), currency.Format(originalAmount, saleCurrency))
+}
+
+// formatRate renders a conversion rate without trailing zeros
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// handleSlashRates handles the /docteur rates command group
+func handleSlashRates(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(data.Options) == 0 {
+		return
+	}
+
+	subCommand := data.Options[0]
+	switch subCommand.Name {
+	case "set":
+		handleSlashRatesSet(s, i, subCommand)
+	case "view":
+		handleSlashRatesView(s, i)
+	}
+}
+
+// handleSlashRatesSet handles the /docteur rates set command
+func handleSlashRatesSet(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Rates] Processing rate update from user %s", i.Member.User.Username)
+
+	// Only admins can change conversion rates
+	if !isLootAdmin(i) {
+		log.Printf("[Rates] Rejected: User %s is not a loot admin", i.Member.User.Username)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Only loot admins can set conversion rates.",
+			},
+		})
+		return
+	}
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	rateCurrency, err := currency.Parse(optionMap["currency"].StringValue())
+	if err != nil || rateCurrency.IsBase() {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("❌ Rates can only be set for currencies other than %s.", currency.Base.Name()),
+			},
+		})
+		return
+	}
+
+	rate := optionMap["rate"].FloatValue()
+	if rate <= 0 {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ The rate must be greater than zero.",
+			},
+		})
+		return
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	if err := db.SetCurrencyRate(i.GuildID, string(rateCurrency), rate, actorOf(i)); err != nil {
+		log.Printf("[Rates] Failed to set rate: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to set conversion rate: " + err.Error()),
+		})
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Conversion Rate Updated",
+		Description: fmt.Sprintf("1 %s is now worth %s %s", rateCurrency.SingularName(), formatRate(rate), currency.Base.Name()),
+		Color:       0x00ff00,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Set By",
+				Value:  i.Member.User.Mention(),
+				Inline: true,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Rates] Set %s rate to %s", rateCurrency, formatRate(rate))
+}
+
+// handleSlashRatesView handles the /docteur rates view command
+func handleSlashRatesView(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	log.Printf("[Rates] Processing rates view from user %s", i.Member.User.Username)
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	rates, err := db.ListCurrencyRates(i.GuildID)
+	if err != nil {
+		log.Printf("[Rates] Failed to list rates: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get conversion rates: " + err.Error()),
+		})
+		return
+	}
+
+	if len(rates) == 0 {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("No conversion rates have been set. Admins can use `/docteur rates set`."),
+		})
+		return
+	}
+
+	var ratesValue strings.Builder
+	for _, rate := range rates {
+		rateCurrency, err := currency.Parse(rate.Currency)
+		if err != nil {
+			continue
+		}
+		ratesValue.WriteString(fmt.Sprintf("1 %s = %s %s (set by <@%s> on %s)\n",
+			rateCurrency.SingularName(), formatRate(rate.Rate), currency.Base.ShortName(),
+			rate.SetBy, rate.CreatedAt.Format("Jan 02, 2006 15:04")))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Conversion Rates",
+		Description: fmt.Sprintf("All sales are converted to %s for shares and the leaderboard", currency.Base.Name()),
+		Color:       0x00ffff,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Current Rates",
+				Value:  ratesValue.String(),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+}
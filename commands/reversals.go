@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/currency"
+	"github.com/intinig/dr-peste/db"
+)
+
+// handleSlashCancel handles the /docteur cancel command
+func handleSlashCancel(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Cancel] Processing cancel request from user %s", i.Member.User.Username)
+
+	item, reason, override, ok := loadReversibleItem(s, i, data, "Cancel")
+	if !ok {
+		return
+	}
+
+	if item.Status != "assigned" || item.SoldQuantity > 0 {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Only items that haven't been sold can be cancelled. Use `/docteur unsell` to reverse their sales first."),
+		})
+		return
+	}
+
+	actor := overrideActor(i, override, "cancel", reason)
+	if err := db.CancelItem(i.GuildID, item.ID, reason, actor); err != nil {
+		log.Printf("[Cancel] Failed to cancel item #%d: %v", item.ID, err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to cancel item: " + err.Error()),
+		})
+		return
+	}
+
+	logOverride(i, actor, item.ID)
+
+	fields := []*discordgo.MessageEmbedField{
+		{
+			Name:   "Cancelled By",
+			Value:  i.Member.User.Mention(),
+			Inline: true,
+		},
+	}
+	if reason != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Reason",
+			Value:  reason,
+			Inline: false,
+		})
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Item Cancelled",
+		Description: fmt.Sprintf("Item **%s** (ID: **%d**) has been cancelled and will no longer be sold", item.Name, item.ID),
+		Color:       0xff0000,
+		Fields:      fields,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Cancel] Successfully cancelled item #%d", item.ID)
+}
+
+// handleSlashUnsell handles the /docteur unsell command
+func handleSlashUnsell(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Unsell] Processing unsell request from user %s", i.Member.User.Username)
+
+	item, reason, override, ok := loadReversibleItem(s, i, data, "Unsell")
+	if !ok {
+		return
+	}
+
+	// Without a sale ID the latest standing sale is reversed
+	var saleID int64
+	for _, opt := range data.Options {
+		if opt.Name == "sale" {
+			saleID = opt.IntValue()
+		}
+	}
+
+	if item.SoldQuantity == 0 {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Only sold items can be unsold. Use `/docteur cancel` for unsold items."),
+		})
+		return
+	}
+
+	actor := overrideActor(i, override, "unsell", reason)
+	sale, err := db.UnsellItem(i.GuildID, item.ID, saleID, reason, actor)
+	if err != nil {
+		log.Printf("[Unsell] Failed to unsell item #%d: %v", item.ID, err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to reverse sale: " + err.Error()),
+		})
+		return
+	}
+
+	logOverride(i, actor, item.ID)
+
+	// The compensating entries show what was reversed
+	shares, err := db.GetSaleShares(i.GuildID, sale.ID)
+	if err != nil {
+		log.Printf("[Unsell] Failed to get shares of sale #%d: %v", sale.ID, err)
+	}
+
+	// Build the list of reversed shares
+	var sharesValue strings.Builder
+	for _, share := range shares {
+		if share.Amount >= 0 {
+			continue
+		}
+
+		note := "voided"
+		switch {
+		case share.UserID == db.BankUserID:
+			note = fmt.Sprintf("returned to <@%s>", share.SellerID)
+		case share.Status == db.ShareUnpaid:
+			note = fmt.Sprintf("owes <@%s> back", share.SellerID)
+		}
+		if share.Kind != db.KindShare {
+			note = share.Kind + ", " + note
+		}
+		sharesValue.WriteString(fmt.Sprintf("%s: -%s (%s)\n", memberMention(share.UserID), currency.FormatBase(-share.Amount), note))
+	}
+	if sharesValue.Len() == 0 {
+		sharesValue.WriteString("No shares to reverse")
+	}
+
+	fields := []*discordgo.MessageEmbedField{
+		{
+			Name:   "Reversed Sale",
+			Value:  fmt.Sprintf("#%d: %d × for %s", sale.ID, sale.Quantity, formatSaleAmount(sale.Amount, sale.Currency, sale.OriginalAmount)),
+			Inline: true,
+		},
+		{
+			Name:   "Reversed By",
+			Value:  i.Member.User.Mention(),
+			Inline: true,
+		},
+		{
+			Name:   "Compensating Entries",
+			Value:  sharesValue.String(),
+			Inline: false,
+		},
+	}
+	if reason != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Reason",
+			Value:  reason,
+			Inline: false,
+		})
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Sale Reversed",
+		Description: fmt.Sprintf("The sale of %d × **%s** (ID: **%d**) was reversed. They are pending sale again.", sale.Quantity, item.Name, item.ID),
+		Color:       0xffa500,
+		Fields:      fields,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Unsell] Successfully reversed sale #%d of item #%d", sale.ID, item.ID)
+}
+
+// loadReversibleItem acknowledges the interaction and loads the item to cancel or unsell.
+// Only the seller or a loot admin may reverse an item; override is true when a loot admin reverses someone
+// else's item. It returns false if a response was already sent.
+func loadReversibleItem(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption, logPrefix string) (item *db.Item, reason string, override bool, ok bool) {
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	itemID, err := strconv.ParseInt(optionMap["item"].StringValue(), 10, 64)
+	if err != nil {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Invalid item ID. Please provide a valid number.",
+			},
+		})
+		return nil, "", false, false
+	}
+
+	if reasonOpt, ok := optionMap["reason"]; ok {
+		reason = strings.TrimSpace(reasonOpt.StringValue())
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	item, err = db.GetItem(i.GuildID, itemID)
+	if err != nil {
+		log.Printf("Error getting item: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get item: " + err.Error()),
+		})
+		return nil, "", false, false
+	}
+
+	allowed, override := canActOnItem(i, item)
+	if !allowed {
+		log.Printf("[%s] Rejected: User %s is not the seller of item #%d", logPrefix, i.Member.User.Username, itemID)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Only the seller or a loot admin can do this."),
+		})
+		return nil, "", false, false
+	}
+
+	return item, reason, override, true
+}
+
+// formatReversal renders a reversal for an item's history
+func formatReversal(reversal db.Reversal) string {
+	var line string
+	switch reversal.Action {
+	case db.ReversalCancel:
+		line = fmt.Sprintf("🚫 Cancelled by <@%s> on %s", reversal.ActorID, reversal.CreatedAt.Format("Jan 02, 2006 15:04"))
+	case db.ReversalUnsell:
+		sale := formatSaleAmount(reversal.SaleAmount, reversal.SaleCurrency, reversal.SaleOriginalAmount)
+		if reversal.SaleID != 0 {
+			sale = fmt.Sprintf("#%d for %s", reversal.SaleID, sale)
+		}
+		line = fmt.Sprintf("🔄 Sale %s reversed by <@%s> on %s", sale, reversal.ActorID, reversal.CreatedAt.Format("Jan 02, 2006 15:04"))
+	}
+
+	if reversal.Reason != "" {
+		line += ": " + reversal.Reason
+	}
+	return line
+}
+
+// formatItemSale renders one sale of an item's stack, struck through if it was reversed
+func formatItemSale(sale db.Sale) string {
+	line := fmt.Sprintf("#%d: %d × for %s (%s each) on %s", sale.ID, sale.Quantity,
+		formatSaleAmount(sale.Amount, sale.Currency, sale.OriginalAmount), currency.FormatBase(int64(sale.UnitPrice())), sale.CreatedAt.Format("Jan 02"))
+	if sale.Status == db.SaleReversed {
+		line = "~~" + line + "~~ reversed"
+	}
+	return line
+}
@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/currency"
+	"github.com/intinig/dr-peste/db"
+)
+
+// handleSlashSession handles the /docteur session command group
+func handleSlashSession(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(data.Options) == 0 {
+		return
+	}
+
+	subCommand := data.Options[0]
+	switch subCommand.Name {
+	case "start":
+		handleSlashSessionStart(s, i, subCommand)
+	case "end":
+		handleSlashSessionEnd(s, i)
+	}
+}
+
+// handleSlashSessionStart handles the /docteur session start command
+func handleSlashSessionStart(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Session] Processing session start from user %s", i.Member.User.Username)
+
+	// Extract options
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(data.Options))
+	for _, opt := range data.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	// Whoever starts the session is part of it
+	uniqueParticipants := map[string]bool{i.Member.User.ID: true}
+	participants := []string{i.Member.User.ID}
+
+	var voice bool
+	if voiceOpt, ok := optionMap["voice"]; ok {
+		voice = voiceOpt.BoolValue()
+	}
+
+	participantsOpt, mentioned := optionMap["participants"]
+	if !mentioned && !voice {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Mention the members of the party, or set `voice` to add everyone in your voice channel.",
+			},
+		})
+		return
+	}
+
+	participants, err := collectParticipants(s, i, participantsOpt, voice, participants, uniqueParticipants)
+	if err != nil {
+		log.Printf("[Session] Rejected: %v", err)
+		respondEphemeral(s, i, rejectionMessage(err))
+		return
+	}
+
+	name := fmt.Sprintf("%s's run on %s", i.Member.User.Username, time.Now().Format("Jan 02"))
+	if nameOpt, ok := optionMap["name"]; ok && strings.TrimSpace(nameOpt.StringValue()) != "" {
+		name = strings.TrimSpace(nameOpt.StringValue())
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	session, err := db.StartSession(i.GuildID, i.ChannelID, name, participants, actorOf(i))
+	if err != nil {
+		log.Printf("[Session] Failed to start session: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to start session: " + err.Error()),
+		})
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🗺️ Session Started: %s", session.Name),
+		Description: "Items added with `/docteur add` in this channel now belong to this session and are shared with its participants unless others are mentioned. End it with `/docteur session end`.",
+		Color:       0x00ffff,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   fmt.Sprintf("Participants (%d)", len(session.Participants)),
+				Value:  formatMentions(session.Participants),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Session] Started session #%d: %s", session.ID, session.Name)
+}
+
+// handleSlashSessionEnd handles the /docteur session end command by posting a summary of the session
+func handleSlashSessionEnd(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	log.Printf("[Session] Processing session end from user %s", i.Member.User.Username)
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	active, err := db.GetActiveSession(i.GuildID, i.ChannelID)
+	if err != nil {
+		log.Printf("[Session] Failed to get active session: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get session: " + err.Error()),
+		})
+		return
+	}
+
+	if active == nil {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("No session is running in this channel. Start one with `/docteur session start`."),
+		})
+		return
+	}
+
+	override := active.CreatedBy != i.Member.User.ID
+	if override && !isLootAdmin(i) {
+		log.Printf("[Session] Rejected: User %s did not start session #%d", i.Member.User.Username, active.ID)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr(fmt.Sprintf("❌ Only <@%s>, who started the session, or a loot admin can end it.", active.CreatedBy)),
+		})
+		return
+	}
+
+	actor := overrideActor(i, override, "end session", fmt.Sprintf("started by <@%s>", active.CreatedBy))
+	session, err := db.EndSession(i.GuildID, i.ChannelID, actor)
+	if err != nil {
+		log.Printf("[Session] Failed to end session: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to end session: " + err.Error()),
+		})
+		return
+	}
+
+	logOverride(i, actor, 0)
+
+	summary, err := db.GetSessionSummary(i.GuildID, session.ID)
+	if err != nil {
+		log.Printf("[Session] Failed to get session summary: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ The session has ended, but its summary failed: " + err.Error()),
+		})
+		return
+	}
+
+	// Unsold items are still worth their estimate, so show what the session may end up being worth
+	items := fmt.Sprintf("%d items", summary.ItemCount)
+	if summary.UnsoldCount > 0 {
+		items += fmt.Sprintf(" (%d still for sale)", summary.UnsoldCount)
+	}
+
+	heads := int64(len(session.Participants))
+	perHead := currency.FormatBase(summary.RealizedTotal / heads)
+	if summary.UnsoldCount > 0 {
+		perHead += fmt.Sprintf(" so far, ~%s estimated", currency.FormatBase(summary.EstimatedTotal/heads))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🏁 Session Ended: %s", session.Name),
+		Description: fmt.Sprintf("Ran for %s, ended by %s", formatDuration(session.EndedAt.Sub(session.StartedAt)), i.Member.User.Mention()),
+		Color:       0x00ff00,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Drops",
+				Value:  items,
+				Inline: true,
+			},
+			{
+				Name:   "Estimated Total",
+				Value:  currency.FormatBase(summary.EstimatedTotal),
+				Inline: true,
+			},
+			{
+				Name:   "Realized Total",
+				Value:  currency.FormatBase(summary.RealizedTotal),
+				Inline: true,
+			},
+			{
+				Name:   "Per Head",
+				Value:  perHead,
+				Inline: true,
+			},
+			{
+				Name:   fmt.Sprintf("Participants (%d)", heads),
+				Value:  formatMentions(session.Participants),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+
+	log.Printf("[Session] Ended session #%d: %d items, %d realized", session.ID, summary.ItemCount, summary.RealizedTotal)
+}
+
+// formatMentions renders user IDs as mentions, one per line
+func formatMentions(userIDs []string) string {
+	var mentions strings.Builder
+	for _, userID := range userIDs {
+		mentions.WriteString(fmt.Sprintf("<@%s>\n", userID))
+	}
+	return mentions.String()
+}
+
+// formatDuration renders a duration in hours and minutes, e.g. "2h 05m"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/intinig/dr-peste/currency"
+	"github.com/intinig/dr-peste/db"
+)
+
+// settleTransferPrefix prefixes the custom ID of "transfer sent" buttons
+const settleTransferPrefix = "settle_transfer"
+
+// handleSlashSettle handles the /docteur settle command
+func handleSlashSettle(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.ApplicationCommandInteractionDataOption) {
+	log.Printf("[Settle] Processing settle request from user %s", i.Member.User.Username)
+
+	var cancel bool
+	for _, opt := range data.Options {
+		if opt.Name == "cancel" {
+			cancel = opt.BoolValue()
+		}
+	}
+
+	// Acknowledge the interaction
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	open, err := db.GetOpenSettlement(i.GuildID)
+	if err != nil {
+		log.Printf("[Settle] Failed to get open settlement: %v", err)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("❌ Failed to get open settlement: " + err.Error()),
+		})
+		return
+	}
+
+	if cancel {
+		if open == nil {
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("There is no open settlement to cancel."),
+			})
+			return
+		}
+
+		override := open.CreatedBy != i.Member.User.ID
+		if override && !isLootAdmin(i) {
+			log.Printf("[Settle] Rejected: User %s cannot cancel settlement #%d", i.Member.User.Username, open.ID)
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("❌ Only the member who created the settlement or a loot admin can cancel it."),
+			})
+			return
+		}
+
+		actor := overrideActor(i, override, "cancel settlement", fmt.Sprintf("created by <@%s>", open.CreatedBy))
+		if err := db.CancelSettlement(i.GuildID, open.ID, actor); err != nil {
+			log.Printf("[Settle] Failed to cancel settlement #%d: %v", open.ID, err)
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("❌ Failed to cancel settlement: " + err.Error()),
+			})
+			return
+		}
+
+		logOverride(i, actor, 0)
+
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr(fmt.Sprintf("🗑️ Settlement #%d was cancelled. Its shares are unpaid again.", open.ID)),
+		})
+		log.Printf("[Settle] Cancelled settlement #%d", open.ID)
+		return
+	}
+
+	// Re-post the open plan instead of creating overlapping transfers
+	settlement := open
+	if settlement == nil {
+		settlement, err = db.CreateSettlement(i.GuildID, actorOf(i))
+		if err != nil {
+			log.Printf("[Settle] Failed to create settlement: %v", err)
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: strPtr("❌ Failed to create settlement: " + err.Error()),
+			})
+			return
+		}
+	}
+
+	if settlement == nil {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: strPtr("✅ There are no unpaid shares to settle."),
+		})
+		return
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &[]*discordgo.MessageEmbed{settlementEmbed(settlement)},
+		Components: buttonRows(settlementButtons(s, settlement)),
+	})
+
+	log.Printf("[Settle] Displayed settlement #%d with %d transfers", settlement.ID, len(settlement.Transfers))
+}
+
+// handleSettleTransfer handles a payer pressing a "transfer sent" button
+func handleSettleTransfer(s *discordgo.Session, i *discordgo.InteractionCreate, transferIDStr string) {
+	transferID, err := strconv.ParseInt(transferIDStr, 10, 64)
+	if err != nil {
+		respondEphemeral(s, i, "❌ Invalid transfer.")
+		return
+	}
+
+	settlement, err := db.ConfirmSettlementTransfer(i.GuildID, transferID, actorOf(i))
+	if err != nil {
+		log.Printf("[Settle] User %s could not confirm transfer #%d: %v", i.Member.User.Username, transferID, err)
+		respondEphemeral(s, i, "❌ "+err.Error())
+		return
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{settlementEmbed(settlement)},
+			Components: *buttonRows(settlementButtons(s, settlement)),
+		},
+	})
+	if err != nil {
+		log.Printf("[Settle] Error updating settlement message: %v", err)
+	}
+
+	log.Printf("[Settle] User %s confirmed transfer #%d of settlement #%d", i.Member.User.Username, transferID, settlement.ID)
+}
+
+// settlementEmbed renders a settlement plan and the progress of its transfers
+func settlementEmbed(settlement *db.Settlement) *discordgo.MessageEmbed {
+	var transfersValue strings.Builder
+	confirmed := 0
+	for idx, transfer := range settlement.Transfers {
+		statusEmoji := "⏳"
+		if transfer.Confirmed() {
+			statusEmoji = "✅"
+			confirmed++
+		}
+		transfersValue.WriteString(fmt.Sprintf("%s **%d.** <@%s> → <@%s>: %s\n",
+			statusEmoji, idx+1, transfer.FromUser, transfer.ToUser, currency.FormatBase(transfer.Amount)))
+	}
+	if transfersValue.Len() == 0 {
+		transfersValue.WriteString("All balances already cancel out, no transfers needed.")
+	}
+
+	title := fmt.Sprintf("Settlement #%d", settlement.ID)
+	color := 0xffa500
+	description := fmt.Sprintf("Clears %d unpaid shares with %d transfers. Senders, press the button once you traded the orbs.",
+		settlement.ShareCount, len(settlement.Transfers))
+	if settlement.Status == db.SettlementCompleted {
+		title += " - Complete"
+		color = 0x00ff00
+		description = fmt.Sprintf("All transfers were confirmed and %d shares have been marked as paid.", settlement.ShareCount)
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description,
+		Color:       color,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   fmt.Sprintf("Transfers (%d/%d confirmed)", confirmed, len(settlement.Transfers)),
+				Value:  transfersValue.String(),
+				Inline: false,
+			},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Planned by %s", settlement.CreatedAt.Format("Jan 02, 2006 15:04")),
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
+
+// settlementButtons returns a "transfer sent" button for every transfer of a settlement
+func settlementButtons(s *discordgo.Session, settlement *db.Settlement) []discordgo.MessageComponent {
+	var buttons []discordgo.MessageComponent
+	for idx, transfer := range settlement.Transfers {
+		// Discord allows at most 25 buttons per message
+		if len(buttons) >= 25 {
+			break
+		}
+
+		label := fmt.Sprintf("%d. %s sent %d", idx+1, userDisplayName(s, transfer.FromUser), transfer.Amount)
+		style := discordgo.PrimaryButton
+		if transfer.Confirmed() {
+			label = fmt.Sprintf("%d. Sent", idx+1)
+			style = discordgo.SuccessButton
+		}
+
+		buttons = append(buttons, discordgo.Button{
+			Label:    label,
+			Style:    style,
+			Disabled: transfer.Confirmed() || settlement.Status != db.SettlementOpen,
+			CustomID: fmt.Sprintf("%s:%d", settleTransferPrefix, transfer.ID),
+		})
+	}
+	return buttons
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// voiceStateCache is the part of the session state that knows who sits in which voice channel.
+// *discordgo.State implements it from the voice states Discord sends with the guild voice states intent.
+type voiceStateCache interface {
+	Guild(guildID string) (*discordgo.Guild, error)
+	Member(guildID string, userID string) (*discordgo.Member, error)
+}
+
+// voiceParticipants returns the members sitting in the same voice channel as a user, the user included.
+// Bots and deafened members are left out, and members in the AFK channel don't count as a party.
+func voiceParticipants(cache voiceStateCache, guildID string, userID string) ([]string, error) {
+	guild, err := cache.Guild(guildID)
+	if err != nil {
+		return nil, fmt.Errorf("the voice channels of this server aren't known yet, try again in a moment")
+	}
+
+	var channelID string
+	for _, vs := range guild.VoiceStates {
+		if vs.UserID == userID {
+			channelID = vs.ChannelID
+			break
+		}
+	}
+
+	if channelID == "" {
+		return nil, fmt.Errorf("you need to be in a voice channel to fill in participants from it")
+	}
+	if channelID == guild.AfkChannelID {
+		return nil, fmt.Errorf("you are in the AFK channel; join your party's voice channel first")
+	}
+
+	var userIDs []string
+	for _, vs := range guild.VoiceStates {
+		if vs.ChannelID != channelID || vs.Deaf || vs.SelfDeaf {
+			continue
+		}
+
+		// Voice states usually carry the member, otherwise it is looked up in the cache
+		member := vs.Member
+		if member == nil || member.User == nil {
+			member, err = cache.Member(guildID, vs.UserID)
+			if err != nil {
+				member = nil
+			}
+		}
+		if member != nil && member.User != nil && member.User.Bot {
+			continue
+		}
+
+		userIDs = append(userIDs, vs.UserID)
+	}
+
+	return userIDs, nil
+}
+
+// collectParticipants adds the mentioned members and, if voice is set, the members of the caller's
+// voice channel to participants. Mentioning someone twice is an error, but members who are both
+// mentioned and in the voice channel are only added once.
+func collectParticipants(s *discordgo.Session, i *discordgo.InteractionCreate, mentionsOpt *discordgo.ApplicationCommandInteractionDataOption, voice bool, participants []string, seen map[string]bool) ([]string, error) {
+	if mentionsOpt != nil {
+		mentioned, err := parseMentions(mentionsOpt.StringValue(), s.State.User.ID, seen)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, mentioned...)
+	}
+
+	if voice {
+		inVoice, err := voiceParticipants(s.State, i.GuildID, i.Member.User.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, userID := range inVoice {
+			if !seen[userID] && userID != s.State.User.ID {
+				seen[userID] = true
+				participants = append(participants, userID)
+			}
+		}
+	}
+
+	return participants, nil
+}
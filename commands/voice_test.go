@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// fakeVoiceCache is a voice state cache holding a single guild
+type fakeVoiceCache struct {
+	guild   *discordgo.Guild
+	members map[string]*discordgo.Member
+}
+
+func (c *fakeVoiceCache) Guild(guildID string) (*discordgo.Guild, error) {
+	if c.guild == nil || c.guild.ID != guildID {
+		return nil, discordgo.ErrStateNotFound
+	}
+	return c.guild, nil
+}
+
+func (c *fakeVoiceCache) Member(guildID string, userID string) (*discordgo.Member, error) {
+	if member, ok := c.members[userID]; ok {
+		return member, nil
+	}
+	return nil, errors.New("member not found")
+}
+
+func TestVoiceParticipants(t *testing.T) {
+	human := func(userID string) *discordgo.Member {
+		return &discordgo.Member{User: &discordgo.User{ID: userID}}
+	}
+
+	cache := &fakeVoiceCache{
+		guild: &discordgo.Guild{
+			ID:           "guild",
+			AfkChannelID: "afk",
+			VoiceStates: []*discordgo.VoiceState{
+				{UserID: "caller", ChannelID: "maps", Member: human("caller")},
+				{UserID: "friend", ChannelID: "maps", Member: human("friend")},
+				{UserID: "uncached", ChannelID: "maps"},
+				{UserID: "music", ChannelID: "maps", Member: &discordgo.Member{User: &discordgo.User{ID: "music", Bot: true}}},
+				{UserID: "cachedbot", ChannelID: "maps"},
+				{UserID: "deafened", ChannelID: "maps", Deaf: true, Member: human("deafened")},
+				{UserID: "selfdeaf", ChannelID: "maps", SelfDeaf: true, Member: human("selfdeaf")},
+				{UserID: "muted", ChannelID: "maps", SelfMute: true, Member: human("muted")},
+				{UserID: "elsewhere", ChannelID: "bossing", Member: human("elsewhere")},
+				{UserID: "sleeper", ChannelID: "afk", Member: human("sleeper")},
+				{UserID: "napper", ChannelID: "afk", Member: human("napper")},
+			},
+		},
+		members: map[string]*discordgo.Member{
+			"cachedbot": {User: &discordgo.User{ID: "cachedbot", Bot: true}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		guildID string
+		userID  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "bots and deafened members are left out",
+			guildID: "guild",
+			userID:  "caller",
+			want:    []string{"caller", "friend", "uncached", "muted"},
+		},
+		{
+			name:    "another channel has its own party",
+			guildID: "guild",
+			userID:  "elsewhere",
+			want:    []string{"elsewhere"},
+		},
+		{
+			name:    "the AFK channel isn't a party",
+			guildID: "guild",
+			userID:  "sleeper",
+			wantErr: true,
+		},
+		{
+			name:    "caller not in voice",
+			guildID: "guild",
+			userID:  "lurker",
+			wantErr: true,
+		},
+		{
+			name:    "guild not cached",
+			guildID: "other",
+			userID:  "caller",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := voiceParticipants(cache, tt.guildID, tt.userID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("voiceParticipants() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("voiceParticipants() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("voiceParticipants() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Package currency describes the orbs sales can be made in and converts them
+// to the base currency used for shares and the leaderboard.
+package currency
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Currency identifies an orb type by its short code
+type Currency string
+
+const (
+	Exalted Currency = "exalted"
+	Divine  Currency = "divine"
+	Chaos   Currency = "chaos"
+)
+
+// Base is the currency every amount is normalized to
+const Base = Exalted
+
+// All lists the supported currencies, base currency first
+var All = []Currency{Exalted, Divine, Chaos}
+
+// Parse converts a currency code into a Currency, defaulting to the base currency when empty
+func Parse(code string) (Currency, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return Base, nil
+	}
+	for _, c := range All {
+		if string(c) == code {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown currency %q", code)
+}
+
+// Name returns the display name of a currency
+func (c Currency) Name() string {
+	switch c {
+	case Exalted:
+		return "Exalted Orbs"
+	case Divine:
+		return "Divine Orbs"
+	case Chaos:
+		return "Chaos Orbs"
+	}
+	return string(c)
+}
+
+// SingularName returns the display name of a single orb
+func (c Currency) SingularName() string {
+	return strings.TrimSuffix(c.Name(), "s")
+}
+
+// ShortName returns the abbreviation players use in trade chat
+func (c Currency) ShortName() string {
+	switch c {
+	case Exalted:
+		return "ex"
+	case Divine:
+		return "div"
+	case Chaos:
+		return "c"
+	}
+	return string(c)
+}
+
+// IsBase reports whether c is the base currency
+func (c Currency) IsBase() bool {
+	return c == Base
+}
+
+// Format renders an amount with the currency's display name
+func Format(amount int64, c Currency) string {
+	return fmt.Sprintf("%d %s", amount, c.Name())
+}
+
+// FormatBase renders an amount of the base currency
+func FormatBase(amount int64) string {
+	return Format(amount, Base)
+}
+
+// ToBase converts an amount using a rate expressed in base currency per unit,
+// rounding to the nearest whole orb
+func ToBase(amount int64, rate float64) int64 {
+	return int64(math.Round(float64(amount) * rate))
+}
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditAdd               = "add"
+	AuditAssign            = "assign"
+	AuditEdit              = "edit"
+	AuditParticipantAdd    = "participant add"
+	AuditParticipantRemove = "participant remove"
+	AuditParticipantWeight = "participant weight"
+	AuditSell              = "sell"
+	AuditCancel            = "cancel"
+	AuditUnsell            = "unsell"
+	AuditPayout            = "payout"
+	AuditConfirmShare      = "confirm share"
+	AuditMergeNames        = "merge names"
+	AuditPriceCheck        = "price check"
+	AuditBankDeposit       = "bank deposit"
+	AuditBankWithdrawal    = "bank withdrawal"
+	AuditConfig            = "config"
+	AuditRate              = "rate"
+	AuditRole              = "role"
+	AuditSessionStart      = "session start"
+	AuditSessionEnd        = "session end"
+	AuditSettlementCreate  = "settlement create"
+	AuditSettlementConfirm = "settlement confirm"
+	AuditSettlementCancel  = "settlement cancel"
+)
+
+// Actor is the member behind a change to the ledger and the Discord interaction they made it with.
+// Every write made on behalf of a member takes one and leaves an audit event; writes the bot makes
+// on its own, like seeding the item catalog, aren't audited.
+type Actor struct {
+	UserID        string
+	InteractionID string
+	Override      *Override // set when a loot admin acts on something they don't own
+}
+
+// AuditEvent is an append-only record of a change to the ledger, with the state of what changed before and after it
+type AuditEvent struct {
+	ID            int64
+	GuildID       string
+	ActorID       string
+	Action        string
+	ItemID        int64  // 0 if the change wasn't to an item
+	Before        string // JSON, empty if the record didn't exist before
+	After         string // JSON, empty if the record doesn't exist after
+	InteractionID string
+	CreatedAt     time.Time
+}
+
+// itemState is the audited state of an item
+type itemState struct {
+	Name           string             `json:"name"`
+	Quantity       int64              `json:"quantity"`
+	Status         string             `json:"status"`
+	Seller         string             `json:"seller,omitempty"`
+	EstimatedValue int64              `json:"estimated_value"`
+	SoldQuantity   int64              `json:"sold_quantity"`
+	SaleAmount     int64              `json:"sale_amount"`
+	UnpaidShares   int64              `json:"unpaid_shares"`
+	Participants   map[string]float64 `json:"participants"` // share weight by user ID
+	Sales          []saleState        `json:"sales,omitempty"`
+}
+
+// saleState is the audited state of a sale of an item
+type saleState struct {
+	ID             int64  `json:"id"`
+	Quantity       int64  `json:"quantity"`
+	Amount         int64  `json:"amount"`
+	Currency       string `json:"currency"`
+	OriginalAmount int64  `json:"original_amount"`
+	Status         string `json:"status"`
+}
+
+// GetItemAuditTrail retrieves every audit event of an item, oldest first
+func GetItemAuditTrail(guildID string, itemID int64) ([]AuditEvent, error) {
+	rows, err := db.Query(`
+		SELECT id, guild_id, actor_id, action, COALESCE(item_id, 0), COALESCE(before_json, ''), COALESCE(after_json, ''), interaction_id, created_at
+		FROM audit_events
+		WHERE guild_id = ? AND item_id = ?
+		ORDER BY id
+	`, guildID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var e AuditEvent
+		if err := rows.Scan(&e.ID, &e.GuildID, &e.ActorID, &e.Action, &e.ItemID, &e.Before, &e.After, &e.InteractionID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// recordAudit appends an audit event. Before and after are stored as JSON; nil means the record didn't exist.
+func recordAudit(tx *sql.Tx, guildID string, actor Actor, action string, itemID int64, before any, after any) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	var item any
+	if itemID != 0 {
+		item = itemID
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO audit_events (guild_id, actor_id, action, item_id, before_json, after_json, interaction_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, guildID, actor.UserID, action, item, beforeJSON, afterJSON, actor.InteractionID, time.Now())
+	return err
+}
+
+// auditItem appends an audit event for a change to an item, with its state after the change read back from tx
+func auditItem(tx *sql.Tx, guildID string, actor Actor, action string, itemID int64, before *itemState) error {
+	after, err := loadItemState(tx, itemID)
+	if err != nil {
+		return err
+	}
+	return recordAudit(tx, guildID, actor, action, itemID, before, after)
+}
+
+// loadItemState reads the audited state of an item, or nil if it doesn't exist
+func loadItemState(tx *sql.Tx, itemID int64) (*itemState, error) {
+	state := &itemState{Participants: make(map[string]float64)}
+	var seller sql.NullString
+	var saleAmount sql.NullInt64
+	err := tx.QueryRow(
+		"SELECT name, quantity, status, assigned_to, estimated_value, sale_amount FROM items WHERE id = ?",
+		itemID,
+	).Scan(&state.Name, &state.Quantity, &state.Status, &seller, &state.EstimatedValue, &saleAmount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	state.Seller = seller.String
+	state.SaleAmount = saleAmount.Int64
+
+	if state.SoldQuantity, err = soldQuantity(tx, itemID); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM profit_history WHERE item_id = ? AND status = ? AND amount > 0 AND user_id != seller_id",
+		itemID, ShareUnpaid,
+	).Scan(&state.UnpaidShares)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT user_id, weight FROM participants WHERE item_id = ?", itemID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var userID string
+		var weight float64
+		if err := rows.Scan(&userID, &weight); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		state.Participants[userID] = weight
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(
+		"SELECT id, quantity, amount, currency, original_amount, status FROM sales WHERE item_id = ? ORDER BY id",
+		itemID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sale saleState
+		if err := rows.Scan(&sale.ID, &sale.Quantity, &sale.Amount, &sale.Currency, &sale.OriginalAmount, &sale.Status); err != nil {
+			return nil, err
+		}
+		state.Sales = append(state.Sales, sale)
+	}
+
+	return state, rows.Err()
+}
+
+// auditJSON encodes an audited state, or returns nil for a record that doesn't exist
+func auditJSON(state any) (any, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	if string(encoded) == "null" {
+		return nil, nil
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Kinds of guild bank ledger entries
+const (
+	BankTax        = "tax"        // taken off a sale; negative when the sale was reversed
+	BankDeposit    = "deposit"    // donated by a member
+	BankWithdrawal = "withdrawal" // paid out to a member, e.g. for map rolling costs
+)
+
+// BankEntry is a single movement of orbs in or out of the guild bank
+type BankEntry struct {
+	ID           int64
+	GuildID      string
+	Kind         string // "tax", "deposit", "withdrawal"
+	Amount       int64  // positive into the bank, negative out of it
+	UserID       string // seller, depositor or recipient
+	AuthorizedBy string // admin who approved a withdrawal
+	ItemID       int64
+	Note         string
+	CreatedAt    time.Time
+}
+
+// DepositToBank records the acting member donating orbs to the guild bank
+func DepositToBank(guildID string, amount int64, note string, actor Actor) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	balance, err := bankBalance(tx, guildID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(
+		"INSERT INTO bank_ledger (guild_id, kind, amount, user_id, note, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		guildID, BankDeposit, amount, actor.UserID, note, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := postJournal(tx, guildID, JournalDeposit, 0, 0, note, bankLines(amount), now); err != nil {
+		return err
+	}
+
+	before := map[string]any{"balance": balance}
+	after := map[string]any{"balance": balance + amount, "note": note}
+	if err := recordAudit(tx, guildID, actor, AuditBankDeposit, 0, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// WithdrawFromBank pays orbs out of the guild bank to a member.
+// The bank can't go below zero, and the acting member is recorded as having authorized the withdrawal.
+func WithdrawFromBank(guildID string, recipientID string, amount int64, note string, actor Actor) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	balance, err := bankBalance(tx, guildID)
+	if err != nil {
+		return err
+	}
+	if amount > balance {
+		return fmt.Errorf("the bank only holds %d, can't withdraw %d", balance, amount)
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(
+		"INSERT INTO bank_ledger (guild_id, kind, amount, user_id, authorized_by, note, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		guildID, BankWithdrawal, -amount, recipientID, actor.UserID, note, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := postJournal(tx, guildID, JournalWithdrawal, 0, 0, note, bankLines(-amount), now); err != nil {
+		return err
+	}
+
+	before := map[string]any{"balance": balance}
+	after := map[string]any{"balance": balance - amount, "recipient": recipientID, "note": note}
+	if err := recordAudit(tx, guildID, actor, AuditBankWithdrawal, 0, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetBankBalance returns the number of orbs currently in a guild's bank
+func GetBankBalance(guildID string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	return bankBalance(tx, guildID)
+}
+
+// GetBankHistory retrieves the most recent movements of a guild's bank, newest first
+func GetBankHistory(guildID string, limit int) ([]BankEntry, error) {
+	rows, err := db.Query(`
+		SELECT id, guild_id, kind, amount, user_id, authorized_by, item_id, note, created_at
+		FROM bank_ledger
+		WHERE guild_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, guildID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []BankEntry
+	for rows.Next() {
+		var entry BankEntry
+		var authorizedBy sql.NullString
+		var itemID sql.NullInt64
+		if err := rows.Scan(
+			&entry.ID, &entry.GuildID, &entry.Kind, &entry.Amount, &entry.UserID,
+			&authorizedBy, &itemID, &entry.Note, &entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entry.AuthorizedBy = authorizedBy.String
+		entry.ItemID = itemID.Int64
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// bankBalance sums a guild's bank ledger inside a transaction
+func bankBalance(tx *sql.Tx, guildID string) (int64, error) {
+	var balance int64
+	err := tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM bank_ledger WHERE guild_id = ?", guildID).Scan(&balance)
+	return balance, err
+}
+
+// recordBankTax adds a sale's tax, or its reversal, to the bank ledger
+func recordBankTax(tx *sql.Tx, guildID string, sellerID string, itemID int64, saleID int64, amount int64, note string, now time.Time) error {
+	_, err := tx.Exec(
+		"INSERT INTO bank_ledger (guild_id, kind, amount, user_id, item_id, sale_id, note, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		guildID, BankTax, amount, sellerID, itemID, saleID, note, now,
+	)
+	return err
+}
@@ -0,0 +1,321 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intinig/dr-peste/catalog"
+)
+
+// suggestionScore is the lowest similarity a catalog name needs to be suggested for a name that isn't in it
+const suggestionScore = 0.5
+
+// NameMatch is the result of looking up a typed item name in the catalog
+type NameMatch struct {
+	Name       string  // canonical name if the name is known, the typed name otherwise
+	Matched    bool    // the name is in the catalog, is one of its aliases, or was merged by an admin
+	Suggestion string  // closest catalog name when the name isn't known, if any is close enough
+	Score      float64 // similarity of the suggestion
+}
+
+// UnmatchedName is a name typed in a guild that isn't in the catalog, waiting for an admin to merge it
+type UnmatchedName struct {
+	ID          int64
+	GuildID     string
+	Name        string
+	Suggestion  string // closest catalog name when the name was last seen
+	SeenCount   int64  // times the name was used for an item or a price check
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// seedCatalog loads the bundled catalog into the database. Existing entries are updated
+// in place so their IDs stay stable, and aliases follow the bundled file if it moves them.
+func seedCatalog() error {
+	entries, err := catalog.Bundled()
+	if err != nil {
+		return fmt.Errorf("failed to read bundled catalog: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, entry := range entries {
+		_, err := tx.Exec(`
+			INSERT INTO item_catalog (name, category, created_at) VALUES (?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET name = excluded.name, category = excluded.category
+		`, entry.Name, entry.Category, now)
+		if err != nil {
+			return err
+		}
+
+		var catalogID int64
+		if err := tx.QueryRow("SELECT id FROM item_catalog WHERE name = ?", entry.Name).Scan(&catalogID); err != nil {
+			return err
+		}
+
+		// The canonical name is an alias of itself so lookups only need one table
+		for _, alias := range append([]string{entry.Name}, entry.Aliases...) {
+			_, err := tx.Exec(`
+				INSERT INTO item_aliases (alias, catalog_id) VALUES (?, ?)
+				ON CONFLICT(alias) DO UPDATE SET catalog_id = excluded.catalog_id
+			`, catalog.Normalize(alias), catalogID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListCatalog retrieves every catalog entry with its aliases, by name
+func ListCatalog() ([]catalog.Entry, error) {
+	rows, err := db.Query(`
+		SELECT c.name, c.category, a.alias
+		FROM item_catalog c
+		LEFT JOIN item_aliases a ON a.catalog_id = c.id
+		ORDER BY c.name, a.alias
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []catalog.Entry
+	for rows.Next() {
+		var name, category string
+		var alias sql.NullString
+		if err := rows.Scan(&name, &category, &alias); err != nil {
+			return nil, err
+		}
+
+		if len(entries) == 0 || entries[len(entries)-1].Name != name {
+			entries = append(entries, catalog.Entry{Name: name, Category: category})
+		}
+		if alias.Valid && alias.String != catalog.Normalize(name) {
+			last := &entries[len(entries)-1]
+			last.Aliases = append(last.Aliases, alias.String)
+		}
+	}
+
+	return entries, rows.Err()
+}
+
+// ResolveItemName looks up a typed item name in the catalog. Names an admin merged in the guild,
+// known names and aliases resolve to their canonical name; anything else keeps the typed name
+// along with the closest suggestion.
+func ResolveItemName(guildID string, name string) (*NameMatch, error) {
+	name = strings.TrimSpace(name)
+
+	var canonical string
+	err := db.QueryRow(
+		"SELECT name FROM name_aliases WHERE guild_id = ? AND alias = ?",
+		guildID, catalog.Normalize(name),
+	).Scan(&canonical)
+	if err == nil {
+		return &NameMatch{Name: canonical, Matched: true, Score: 1}, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	err = db.QueryRow(`
+		SELECT c.name FROM item_aliases a
+		JOIN item_catalog c ON a.catalog_id = c.id
+		WHERE a.alias = ?
+	`, catalog.Normalize(name)).Scan(&canonical)
+	if err == nil {
+		return &NameMatch{Name: canonical, Matched: true, Score: 1}, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	entries, err := ListCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	match := &NameMatch{Name: name}
+	if ranked := catalog.Rank(name, entries, suggestionScore); len(ranked) > 0 {
+		match.Suggestion = ranked[0].Name
+		match.Score = ranked[0].Score
+	}
+	return match, nil
+}
+
+// FlagUnmatchedName records that a name which isn't in the catalog was used in a guild
+func FlagUnmatchedName(guildID string, name string, suggestion string) error {
+	now := time.Now()
+	_, err := db.Exec(`
+		INSERT INTO unmatched_names (guild_id, name, suggestion, seen_count, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, 1, ?, ?)
+		ON CONFLICT(guild_id, name) DO UPDATE SET
+			suggestion = excluded.suggestion,
+			seen_count = seen_count + 1,
+			last_seen_at = excluded.last_seen_at
+	`, guildID, strings.TrimSpace(name), suggestion, now, now)
+	return err
+}
+
+// ListUnmatchedNames retrieves the names of a guild waiting to be merged, most used first
+func ListUnmatchedNames(guildID string) ([]UnmatchedName, error) {
+	rows, err := db.Query(`
+		SELECT id, guild_id, name, suggestion, seen_count, first_seen_at, last_seen_at
+		FROM unmatched_names
+		WHERE guild_id = ?
+		ORDER BY seen_count DESC, last_seen_at DESC
+	`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []UnmatchedName
+	for rows.Next() {
+		var n UnmatchedName
+		if err := rows.Scan(&n.ID, &n.GuildID, &n.Name, &n.Suggestion, &n.SeenCount, &n.FirstSeenAt, &n.LastSeenAt); err != nil {
+			return nil, err
+		}
+		names = append(names, n)
+	}
+
+	return names, rows.Err()
+}
+
+// NameMerge describes the rows touched by merging one item name into another
+type NameMerge struct {
+	From         string
+	To           string
+	Items        []Item // items named From, with their ID, name, quantity, status and seller
+	Observations int64  // price observations recorded under From, leaving out those of reversed sales
+}
+
+// PreviewNameMerge lists what merging one item name into another would change, without changing it.
+// Names are compared ignoring case, so every spelling of the name that only differs in case is included.
+func PreviewNameMerge(guildID string, from string, to string) (*NameMerge, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return previewNameMerge(tx, guildID, from, to)
+}
+
+// MergeItemNames renames every item and price observation of a guild named from to to, in one transaction.
+// The old name is recorded as an alias so it resolves to the new one from then on, and is no longer flagged as unmatched.
+func MergeItemNames(guildID string, from string, to string, actor Actor) (*NameMerge, error) {
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("item names can't be empty")
+	}
+	if from == to {
+		return nil, fmt.Errorf("an item name can't be merged into itself")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	merge, err := previewNameMerge(tx, guildID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, item := range merge.Items {
+		before, err := loadItemState(tx, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE items SET name = ?, updated_at = ? WHERE id = ?", to, now, item.ID); err != nil {
+			return nil, err
+		}
+		if err := auditItem(tx, guildID, actor, AuditMergeNames, item.ID, before); err != nil {
+			return nil, err
+		}
+	}
+
+	// The merged history is what estimates of the new name are based on
+	_, err = tx.Exec(
+		"UPDATE price_observations SET item_name = ? WHERE guild_id = ? AND item_name = ? COLLATE NOCASE",
+		to, guildID, from,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names merged into the old name earlier follow it to the new one
+	if _, err := tx.Exec("UPDATE name_aliases SET name = ? WHERE guild_id = ? AND name = ? COLLATE NOCASE", to, guildID, from); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO name_aliases (guild_id, alias, name, created_by, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(guild_id, alias) DO UPDATE SET name = excluded.name, created_by = excluded.created_by, created_at = excluded.created_at
+	`, guildID, catalog.Normalize(from), to, actor.UserID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM unmatched_names WHERE guild_id = ? AND name = ?", guildID, from); err != nil {
+		return nil, err
+	}
+
+	after := map[string]any{"alias": from, "name": to, "items": len(merge.Items), "observations": merge.Observations}
+	if err := recordAudit(tx, guildID, actor, AuditMergeNames, 0, nil, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+// previewNameMerge collects the items and counts the price observations named from
+func previewNameMerge(tx *sql.Tx, guildID string, from string, to string) (*NameMerge, error) {
+	merge := &NameMerge{From: strings.TrimSpace(from), To: strings.TrimSpace(to)}
+
+	rows, err := tx.Query(`
+		SELECT id, name, quantity, status, COALESCE(assigned_to, '')
+		FROM items
+		WHERE guild_id = ? AND name = ? COLLATE NOCASE
+		ORDER BY id
+	`, guildID, merge.From)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := Item{GuildID: guildID}
+		if err := rows.Scan(&item.ID, &item.Name, &item.Quantity, &item.Status, &item.AssignedTo); err != nil {
+			return nil, err
+		}
+		merge.Items = append(merge.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM price_observations WHERE guild_id = ? AND item_name = ? COLLATE NOCASE AND reversed_at IS NULL",
+		guildID, merge.From,
+	).Scan(&merge.Observations)
+	if err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/intinig/dr-peste/distribution"
+)
+
+// BankUserID is the pseudo-participant that collects the guild bank tax in profit_history
+const BankUserID = "bank"
+
+// GuildConfig holds the per-guild settings that change how sales are split
+type GuildConfig struct {
+	GuildID           string
+	CommissionPct     float64 // share of every sale kept by the seller before the split
+	BankTaxPct        float64 // share of every sale paid into the guild bank before the split
+	RemainderStrategy string  // how leftover orbs are handed out on ties, see the distribution package
+	UpdatedBy         string
+	UpdatedAt         time.Time
+}
+
+// Cuts returns the seller commission and bank tax taken off the top of a sale.
+// Both are rounded down so the leftover orbs stay with the participants.
+func (c *GuildConfig) Cuts(saleAmount int64) (commission int64, tax int64) {
+	commission = int64(float64(saleAmount) * c.CommissionPct / 100)
+	tax = int64(float64(saleAmount) * c.BankTaxPct / 100)
+	return commission, tax
+}
+
+// GetGuildConfig returns a guild's settings, or the defaults if none were saved
+func GetGuildConfig(guildID string) (*GuildConfig, error) {
+	config := &GuildConfig{GuildID: guildID, RemainderStrategy: string(distribution.SellerFirst)}
+	var updatedBy sql.NullString
+	var updatedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT commission_pct, bank_tax_pct, remainder_strategy, updated_by, updated_at
+		FROM guild_config WHERE guild_id = ?
+	`, guildID).Scan(&config.CommissionPct, &config.BankTaxPct, &config.RemainderStrategy, &updatedBy, &updatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	config.UpdatedBy = updatedBy.String
+	config.UpdatedAt = updatedAt.Time
+	return config, nil
+}
+
+// SetGuildConfig saves a guild's settings and stamps them with the acting member and the time of the change
+func SetGuildConfig(config *GuildConfig, actor Actor) error {
+	if config.CommissionPct < 0 || config.BankTaxPct < 0 {
+		return fmt.Errorf("percentages can't be negative")
+	}
+	if config.CommissionPct+config.BankTaxPct >= 100 {
+		return fmt.Errorf("commission and tax together must stay below 100%%")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before map[string]any
+	var commission, tax float64
+	var strategy string
+	err = tx.QueryRow(
+		"SELECT commission_pct, bank_tax_pct, remainder_strategy FROM guild_config WHERE guild_id = ?",
+		config.GuildID,
+	).Scan(&commission, &tax, &strategy)
+	switch {
+	case err == nil:
+		before = map[string]any{"commission_pct": commission, "bank_tax_pct": tax, "remainder_strategy": strategy}
+	case err != sql.ErrNoRows:
+		return err
+	}
+
+	config.UpdatedBy = actor.UserID
+	config.UpdatedAt = time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO guild_config (guild_id, commission_pct, bank_tax_pct, remainder_strategy, updated_by, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(guild_id) DO UPDATE SET
+			commission_pct = excluded.commission_pct,
+			bank_tax_pct = excluded.bank_tax_pct,
+			remainder_strategy = excluded.remainder_strategy,
+			updated_by = excluded.updated_by,
+			updated_at = excluded.updated_at
+	`, config.GuildID, config.CommissionPct, config.BankTaxPct, config.RemainderStrategy, config.UpdatedBy, config.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	after := map[string]any{"commission_pct": config.CommissionPct, "bank_tax_pct": config.BankTaxPct, "remainder_strategy": config.RemainderStrategy}
+	if err := recordAudit(tx, config.GuildID, actor, AuditConfig, 0, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -14,7 +14,7 @@ import (
 
 var db *sql.DB
 
-// Initialize sets up the database connection and creates tables if they don't exist
+// Initialize sets up the database connection and applies any pending migrations
 func Initialize() error {
 	// Create data directory if it doesn't exist
 	dataDir := "./data"
@@ -32,61 +32,14 @@ func Initialize() error {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Create tables if they don't exist
-	if err := createTables(); err != nil {
-		return fmt.Errorf("failed to create tables: %w", err)
+	// Bring the schema up to date
+	if err := migrate(); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	return nil
-}
-
-// createTables creates the necessary tables in the database
-func createTables() error {
-	// Items table
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS items (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			estimated_value INTEGER NOT NULL,
-			status TEXT NOT NULL,
-			assigned_to TEXT,
-			sale_amount INTEGER,
-			created_at TIMESTAMP NOT NULL,
-			updated_at TIMESTAMP NOT NULL
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Participants table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS participants (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			item_id INTEGER NOT NULL,
-			user_id TEXT NOT NULL,
-			share_amount INTEGER,
-			FOREIGN KEY (item_id) REFERENCES items(id),
-			UNIQUE(item_id, user_id)
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Profit history table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS profit_history (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id TEXT NOT NULL,
-			item_id INTEGER NOT NULL,
-			amount INTEGER NOT NULL,
-			transaction_date TIMESTAMP NOT NULL,
-			FOREIGN KEY (item_id) REFERENCES items(id)
-		)
-	`)
-	if err != nil {
-		return err
+	// Keep the item catalog in line with the one shipped with the bot
+	if err := seedCatalog(); err != nil {
+		return fmt.Errorf("failed to seed item catalog: %w", err)
 	}
 
 	return nil
@@ -95,11 +48,17 @@ func createTables() error {
 // Item represents an item in the database
 type Item struct {
 	ID             int64
+	GuildID        string
 	Name           string
-	EstimatedValue int64
-	Status         string // "assigned", "sold", "distributed"
+	Quantity       int64
+	EstimatedValue int64  // estimated value in Exalted Orbs when the item was logged
+	Status         string // "assigned", "sold", "distributed", "cancelled"
 	AssignedTo     string
-	SaleAmount     int64
+	SaleAmount     int64  // sale amount normalized to the base currency
+	SaleCurrency   string // currency the item was actually sold in
+	SaleOriginal   int64  // sale amount in SaleCurrency
+	SoldQuantity   int64  // quantity sold in sales that still stand
+	SessionID      int64  // farming session the item dropped in, 0 if none
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	Participants   []Participant
@@ -111,20 +70,28 @@ type Participant struct {
 	ItemID      int64
 	UserID      string
 	ShareAmount int64
+	Weight      float64 // relative size of the share, 1 by default
 }
 
 // ProfitRecord represents a profit transaction in the history
 type ProfitRecord struct {
 	ID              int64
+	GuildID         string
 	UserID          string
+	SellerID        string // seller holding the orbs until the share is paid out
 	ItemID          int64
 	ItemName        string
 	Amount          int64
+	Status          string // "unpaid", "paid", "confirmed", "reversed"
+	Kind            string // "share", "commission", "tax"
+	PaidAt          time.Time
+	ConfirmedAt     time.Time
 	TransactionDate time.Time
 }
 
-// AddItem adds a new item to the database
-func AddItem(name string, estimatedValue int64, participants []string) (int64, error) {
+// AddItem adds a new item to a guild's ledger, as part of a farming session unless sessionID is 0.
+// Participants missing from weights get a weight of 1. Details are only known for items pasted from the game and may be nil.
+func AddItem(guildID string, name string, quantity int64, estimatedValue int64, participants []string, weights map[string]float64, sessionID int64, details *ItemDetails, actor Actor) (int64, error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return 0, err
@@ -133,9 +100,13 @@ func AddItem(name string, estimatedValue int64, participants []string) (int64, e
 
 	// Insert item
 	now := time.Now()
+	var session sql.NullInt64
+	if sessionID != 0 {
+		session = sql.NullInt64{Int64: sessionID, Valid: true}
+	}
 	result, err := tx.Exec(
-		"INSERT INTO items (name, estimated_value, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
-		name, estimatedValue, "assigned", now, now,
+		"INSERT INTO items (guild_id, name, quantity, estimated_value, status, session_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		guildID, name, quantity, estimatedValue, "assigned", session, now, now,
 	)
 	if err != nil {
 		return 0, err
@@ -154,15 +125,33 @@ func AddItem(name string, estimatedValue int64, participants []string) (int64, e
 		userID = strings.TrimPrefix(userID, "!")
 		userID = strings.TrimSuffix(userID, ">")
 
+		weight, ok := weights[userID]
+		if !ok {
+			weight = 1
+		}
+		if weight <= 0 {
+			return 0, fmt.Errorf("weight of <@%s> must be greater than zero", userID)
+		}
+
 		_, err := tx.Exec(
-			"INSERT INTO participants (item_id, user_id) VALUES (?, ?)",
-			itemID, userID,
+			"INSERT INTO participants (guild_id, item_id, user_id, weight) VALUES (?, ?, ?, ?)",
+			guildID, itemID, userID, weight,
 		)
 		if err != nil {
 			return 0, err
 		}
 	}
 
+	if details != nil {
+		if err := insertItemDetails(tx, itemID, details); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := auditItem(tx, guildID, actor, AuditAdd, itemID, nil); err != nil {
+		return 0, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return 0, err
 	}
@@ -170,177 +159,73 @@ func AddItem(name string, estimatedValue int64, participants []string) (int64, e
 	return itemID, nil
 }
 
-// AssignItem assigns an item to a user for selling
-func AssignItem(itemID int64, userID string) error {
+// AssignItem assigns an item to a user for selling.
+// The seller is added as a participant if they weren't one already.
+func AssignItem(guildID string, itemID int64, userID string, actor Actor) error {
 	// Clean up user ID
 	userID = strings.TrimPrefix(userID, "<@")
 	userID = strings.TrimPrefix(userID, "!")
 	userID = strings.TrimSuffix(userID, ">")
 
-	// Update item status
-	_, err := db.Exec(
-		"UPDATE items SET status = ?, assigned_to = ?, updated_at = ? WHERE id = ?",
-		"assigned", userID, time.Now(), itemID,
-	)
-	return err
-}
-
-// MarkItemAsSoldAndDistribute marks an item as sold, calculates shares, and records profit history
-func MarkItemAsSoldAndDistribute(itemID int64, saleAmount int64, shares map[string]int64) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Check if item exists and is in assigned status
-	var status string
-	var itemName string
-	err = tx.QueryRow("SELECT status, name FROM items WHERE id = ?", itemID).Scan(&status, &itemName)
+	before, err := loadItemState(tx, itemID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("item with ID %d not found", itemID)
-		}
 		return err
 	}
 
-	if status != "assigned" {
-		return fmt.Errorf("item must be assigned before it can be sold")
-	}
-
 	// Update item status
-	now := time.Now()
-	_, err = tx.Exec(
-		"UPDATE items SET status = ?, sale_amount = ?, updated_at = ? WHERE id = ?",
-		"distributed", saleAmount, now, itemID,
+	result, err := tx.Exec(
+		"UPDATE items SET status = ?, assigned_to = ?, updated_at = ? WHERE id = ? AND guild_id = ?",
+		"assigned", userID, time.Now(), itemID, guildID,
 	)
 	if err != nil {
 		return err
 	}
 
-	// Update participant shares and record profit history
-	for userID, shareAmount := range shares {
-		// Clean up user ID
-		cleanUserID := strings.TrimPrefix(userID, "<@")
-		cleanUserID = strings.TrimPrefix(cleanUserID, "!")
-		cleanUserID = strings.TrimSuffix(cleanUserID, ">")
-
-		// Update participant share
-		_, err = tx.Exec(
-			"UPDATE participants SET share_amount = ? WHERE item_id = ? AND user_id = ?",
-			shareAmount, itemID, cleanUserID,
-		)
-		if err != nil {
-			return err
-		}
-
-		// Record profit history
-		_, err = tx.Exec(
-			"INSERT INTO profit_history (user_id, item_id, amount, transaction_date) VALUES (?, ?, ?, ?)",
-			cleanUserID, itemID, shareAmount, now,
-		)
-		if err != nil {
-			return err
-		}
-	}
-
-	return tx.Commit()
-}
-
-// MarkItemAsSold marks an item as sold and calculates shares
-func MarkItemAsSold(itemID int64, saleAmount int64) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// Check if item exists and is in assigned status
-	var status string
-	err = tx.QueryRow("SELECT status FROM items WHERE id = ?", itemID).Scan(&status)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("item with ID %d not found", itemID)
-		}
+	if assigned, err := result.RowsAffected(); err != nil {
 		return err
+	} else if assigned == 0 {
+		return fmt.Errorf("item with ID %d not found", itemID)
 	}
 
-	if status != "assigned" {
-		return fmt.Errorf("item must be assigned before it can be sold")
-	}
-
-	// Update item status
+	// The seller shares in what they sell
 	_, err = tx.Exec(
-		"UPDATE items SET status = ?, sale_amount = ?, updated_at = ? WHERE id = ?",
-		"sold", saleAmount, time.Now(), itemID,
+		"INSERT OR IGNORE INTO participants (guild_id, item_id, user_id) VALUES (?, ?, ?)",
+		guildID, itemID, userID,
 	)
 	if err != nil {
 		return err
 	}
 
-	// Count participants
-	var count int
-	err = tx.QueryRow("SELECT COUNT(*) FROM participants WHERE item_id = ?", itemID).Scan(&count)
-	if err != nil {
-		return err
-	}
-
-	if count == 0 {
-		return fmt.Errorf("no participants found for item %d", itemID)
-	}
-
-	// Calculate share amount per participant
-	shareAmount := saleAmount / int64(count)
-
-	// Update participant shares
-	_, err = tx.Exec(
-		"UPDATE participants SET share_amount = ? WHERE item_id = ?",
-		shareAmount, itemID,
-	)
-	if err != nil {
+	if err := auditItem(tx, guildID, actor, AuditAssign, itemID, before); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
-// MarkItemAsDistributed marks an item as distributed
-func MarkItemAsDistributed(itemID int64) error {
-	// Check if item exists and is in sold status
-	var status string
-	err := db.QueryRow("SELECT status FROM items WHERE id = ?", itemID).Scan(&status)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("item with ID %d not found", itemID)
-		}
-		return err
-	}
-
-	if status != "sold" {
-		return fmt.Errorf("item must be sold before it can be distributed")
-	}
-
-	// Update item status
-	_, err = db.Exec(
-		"UPDATE items SET status = ?, updated_at = ? WHERE id = ?",
-		"distributed", time.Now(), itemID,
-	)
-	return err
-}
-
-// GetItem retrieves an item by ID
-func GetItem(itemID int64) (*Item, error) {
+// GetItem retrieves an item by ID, scoped to a guild
+func GetItem(guildID string, itemID int64) (*Item, error) {
 	// Get item details
 	item := &Item{}
 	var nullSaleAmount sql.NullInt64
 	var nullAssignedTo sql.NullString
+	var nullSaleCurrency sql.NullString
+	var nullSaleOriginal sql.NullInt64
 
 	err := db.QueryRow(`
-		SELECT id, name, estimated_value, status, assigned_to, sale_amount, created_at, updated_at
-		FROM items WHERE id = ?
-	`, itemID).Scan(
-		&item.ID, &item.Name, &item.EstimatedValue, &item.Status,
-		&nullAssignedTo, &nullSaleAmount, &item.CreatedAt, &item.UpdatedAt,
+		SELECT id, guild_id, name, quantity, estimated_value, status, assigned_to, sale_amount, sale_currency, sale_original_amount,
+			(SELECT COALESCE(SUM(s.quantity), 0) FROM sales s WHERE s.item_id = items.id AND s.status = 'completed'),
+			COALESCE(session_id, 0), created_at, updated_at
+		FROM items WHERE id = ? AND guild_id = ?
+	`, itemID, guildID).Scan(
+		&item.ID, &item.GuildID, &item.Name, &item.Quantity, &item.EstimatedValue, &item.Status,
+		&nullAssignedTo, &nullSaleAmount, &nullSaleCurrency, &nullSaleOriginal, &item.SoldQuantity, &item.SessionID, &item.CreatedAt, &item.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -363,10 +248,15 @@ func GetItem(itemID int64) (*Item, error) {
 		item.AssignedTo = ""
 	}
 
+	// Sale currency is only set once the item has been sold
+	item.SaleCurrency = nullSaleCurrency.String
+	item.SaleOriginal = nullSaleOriginal.Int64
+
 	// Get participants
 	rows, err := db.Query(`
-		SELECT id, item_id, user_id, share_amount
+		SELECT id, item_id, user_id, share_amount, weight
 		FROM participants WHERE item_id = ?
+		ORDER BY id
 	`, itemID)
 	if err != nil {
 		return nil, err
@@ -377,7 +267,7 @@ func GetItem(itemID int64) (*Item, error) {
 		var p Participant
 		var nullShareAmount sql.NullInt64
 
-		if err := rows.Scan(&p.ID, &p.ItemID, &p.UserID, &nullShareAmount); err != nil {
+		if err := rows.Scan(&p.ID, &p.ItemID, &p.UserID, &nullShareAmount, &p.Weight); err != nil {
 			return nil, err
 		}
 
@@ -394,12 +284,14 @@ func GetItem(itemID int64) (*Item, error) {
 	return item, nil
 }
 
-// ListItems retrieves all items
-func ListItems() ([]Item, error) {
+// ListItems retrieves all items of a guild
+func ListItems(guildID string) ([]Item, error) {
 	rows, err := db.Query(`
-		SELECT id, name, estimated_value, status, assigned_to, sale_amount, created_at, updated_at
-		FROM items ORDER BY created_at DESC
-	`)
+		SELECT id, guild_id, name, quantity, estimated_value, status, assigned_to, sale_amount, sale_currency, sale_original_amount,
+			(SELECT COALESCE(SUM(s.quantity), 0) FROM sales s WHERE s.item_id = items.id AND s.status = 'completed'),
+			COALESCE(session_id, 0), created_at, updated_at
+		FROM items WHERE guild_id = ? ORDER BY created_at DESC
+	`, guildID)
 	if err != nil {
 		return nil, err
 	}
@@ -410,10 +302,12 @@ func ListItems() ([]Item, error) {
 		var item Item
 		var nullSaleAmount sql.NullInt64
 		var nullAssignedTo sql.NullString
+		var nullSaleCurrency sql.NullString
+		var nullSaleOriginal sql.NullInt64
 
 		if err := rows.Scan(
-			&item.ID, &item.Name, &item.EstimatedValue, &item.Status,
-			&nullAssignedTo, &nullSaleAmount, &item.CreatedAt, &item.UpdatedAt,
+			&item.ID, &item.GuildID, &item.Name, &item.Quantity, &item.EstimatedValue, &item.Status,
+			&nullAssignedTo, &nullSaleAmount, &nullSaleCurrency, &nullSaleOriginal, &item.SoldQuantity, &item.SessionID, &item.CreatedAt, &item.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -432,115 +326,130 @@ func ListItems() ([]Item, error) {
 			item.AssignedTo = ""
 		}
 
+		// Sale currency is only set once the item has been sold
+		item.SaleCurrency = nullSaleCurrency.String
+		item.SaleOriginal = nullSaleOriginal.Int64
+
 		items = append(items, item)
 	}
 
 	return items, nil
 }
 
-// GetUserProfitHistory retrieves profit history for a specific user
-func GetUserProfitHistory(userID string) ([]ProfitRecord, error) {
+// GetUserProfitHistory retrieves a guild's profit history for a specific user
+func GetUserProfitHistory(guildID string, userID string) ([]ProfitRecord, error) {
 	// Clean up user ID (remove mentions if present)
 	userID = strings.TrimPrefix(userID, "<@")
 	userID = strings.TrimPrefix(userID, "!")
 	userID = strings.TrimSuffix(userID, ">")
 
 	rows, err := db.Query(`
-		SELECT p.id, p.user_id, p.item_id, i.name, p.amount, p.transaction_date
+		SELECT `+profitRecordColumns+`
 		FROM profit_history p
 		JOIN items i ON p.item_id = i.id
-		WHERE p.user_id = ?
+		WHERE p.guild_id = ? AND p.user_id = ?
 		ORDER BY p.transaction_date DESC
-	`, userID)
+	`, guildID, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var records []ProfitRecord
-	for rows.Next() {
-		var record ProfitRecord
-		if err := rows.Scan(
-			&record.ID, &record.UserID, &record.ItemID, &record.ItemName,
-			&record.Amount, &record.TransactionDate,
-		); err != nil {
-			return nil, err
-		}
-		records = append(records, record)
-	}
-
-	return records, nil
-}
-
-// GetTotalUserProfit calculates the total profit for a user
-func GetTotalUserProfit(userID string) (int64, error) {
-	var total int64
-	err := db.QueryRow(`
-		SELECT COALESCE(SUM(amount), 0)
-		FROM profit_history
-		WHERE user_id = ?
-	`, userID).Scan(&total)
-
-	return total, err
+	return scanProfitRecords(rows)
 }
 
-// GetAllProfitHistory retrieves all profit history records
-func GetAllProfitHistory() ([]ProfitRecord, error) {
+// GetAllProfitHistory retrieves all profit history records of a guild
+func GetAllProfitHistory(guildID string) ([]ProfitRecord, error) {
 	rows, err := db.Query(`
-		SELECT p.id, p.user_id, p.item_id, i.name, p.amount, p.transaction_date
+		SELECT `+profitRecordColumns+`
 		FROM profit_history p
 		JOIN items i ON p.item_id = i.id
+		WHERE p.guild_id = ?
 		ORDER BY p.transaction_date DESC
-	`)
+	`, guildID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var records []ProfitRecord
-	for rows.Next() {
-		var record ProfitRecord
-		if err := rows.Scan(
-			&record.ID, &record.UserID, &record.ItemID, &record.ItemName,
-			&record.Amount, &record.TransactionDate,
-		); err != nil {
-			return nil, err
-		}
-		records = append(records, record)
+	return scanProfitRecords(rows)
+}
+
+// ClaimUnscopedData assigns rows created before multi-guild support to a guild.
+// It returns the number of items that were claimed.
+func ClaimUnscopedData(guildID string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	return records, nil
-}
+	result, err := tx.Exec("UPDATE items SET guild_id = ? WHERE guild_id = ''", guildID)
+	if err != nil {
+		return 0, err
+	}
 
-// GetAveragePrice returns the average sale price for items with the same name
-func GetAveragePrice(itemName string) (float64, error) {
-	rows, err := db.Query(`
-		SELECT sale_amount 
-		FROM items 
-		WHERE name = ? AND status = 'distributed'
-		ORDER BY updated_at DESC
-		LIMIT 5`, itemName)
+	claimed, err := result.RowsAffected()
 	if err != nil {
 		return 0, err
 	}
-	defer rows.Close()
 
-	var total int64
-	var count int
-	for rows.Next() {
-		var amount int64
-		if err := rows.Scan(&amount); err != nil {
+	// Later migrations backfilled the guild ID of sales, prices, bank movements and the like from their
+	// items, so every table with a guild ID may hold unscoped rows. Settings the guild already has win.
+	// The audit log is append-only and was only written once everything was scoped; balances are rebuilt below.
+	tables, err := guildScopedTables(tx)
+	if err != nil {
+		return 0, err
+	}
+	for _, table := range tables {
+		switch table {
+		case "items", "audit_events", "ledger_balances":
+			continue
+		}
+		_, err = tx.Exec(fmt.Sprintf("UPDATE OR IGNORE %s SET guild_id = ? WHERE guild_id = ''", table), guildID)
+		if err != nil {
 			return 0, err
 		}
-		total += amount
-		count++
 	}
 
-	if count == 0 {
-		return 0, nil
+	// The claimed journal entries now count towards the guild's balances
+	if _, err := tx.Exec("DELETE FROM ledger_balances WHERE guild_id = ''"); err != nil {
+		return 0, err
+	}
+	if _, err := rebuildBalances(tx, guildID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return claimed, nil
+}
+
+// guildScopedTables lists every table that has a guild_id column
+func guildScopedTables(tx *sql.Tx) ([]string, error) {
+	rows, err := tx.Query(`
+		SELECT m.name
+		FROM sqlite_master m, pragma_table_info(m.name) c
+		WHERE m.type = 'table' AND c.name = 'guild_id'
+		ORDER BY m.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
 	}
 
-	return float64(total) / float64(count), nil
+	return tables, rows.Err()
 }
 
 // Close closes the database connection
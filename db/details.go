@@ -0,0 +1,52 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// ItemDetails is what was read from the in-game text of an item that was pasted
+type ItemDetails struct {
+	ItemID    int64
+	Class     string // e.g. "Stackable Currency", "Belts"
+	Rarity    string // e.g. "Unique", "Rare", "Currency"
+	BaseType  string
+	ItemLevel int64
+	Corrupted bool
+	Mods      []string
+	RawText   string // the pasted text, kept so it can be read again later
+}
+
+// GetItemDetails retrieves the pasted details of an item, or nil if it was typed in
+func GetItemDetails(guildID string, itemID int64) (*ItemDetails, error) {
+	details := &ItemDetails{}
+	var mods string
+	err := db.QueryRow(`
+		SELECT d.item_id, d.item_class, d.rarity, d.base_type, d.item_level, d.corrupted, d.mods, d.raw_text
+		FROM item_details d
+		JOIN items i ON d.item_id = i.id
+		WHERE d.item_id = ? AND i.guild_id = ?
+	`, itemID, guildID).Scan(
+		&details.ItemID, &details.Class, &details.Rarity, &details.BaseType, &details.ItemLevel, &details.Corrupted, &mods, &details.RawText,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if mods != "" {
+		details.Mods = strings.Split(mods, "\n")
+	}
+	return details, nil
+}
+
+// insertItemDetails stores the pasted details of a new item
+func insertItemDetails(tx *sql.Tx, itemID int64, details *ItemDetails) error {
+	_, err := tx.Exec(`
+		INSERT INTO item_details (item_id, item_class, rarity, base_type, item_level, corrupted, mods, raw_text)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, itemID, details.Class, details.Rarity, details.BaseType, details.ItemLevel, details.Corrupted, strings.Join(details.Mods, "\n"), details.RawText)
+	return err
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ItemEdit describes changes to an unsold item; nil fields are left unchanged
+type ItemEdit struct {
+	Name     *string
+	Quantity *int64
+	SellerID *string
+}
+
+// EditItem renames an unsold item, changes its quantity or reassigns its seller.
+// A new seller is added as a participant if they weren't one already.
+func EditItem(guildID string, itemID int64, edit ItemEdit, actor Actor) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM items WHERE id = ? AND guild_id = ?", itemID, guildID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("item with ID %d not found", itemID)
+		}
+		return err
+	}
+
+	if status != "assigned" {
+		return fmt.Errorf("only unsold items can be edited")
+	}
+
+	before, err := loadItemState(tx, itemID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if edit.Name != nil {
+		name := strings.TrimSpace(*edit.Name)
+		if name == "" {
+			return fmt.Errorf("item name can't be empty")
+		}
+		if _, err := tx.Exec("UPDATE items SET name = ?, updated_at = ? WHERE id = ?", name, now, itemID); err != nil {
+			return err
+		}
+
+		// Prices of stacks already sold belong to the corrected name
+		if err := renameSaleObservations(tx, itemID, name); err != nil {
+			return err
+		}
+	}
+
+	if edit.Quantity != nil {
+		if *edit.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than zero")
+		}
+
+		// Stacks that were already sold can't be taken back by editing
+		sold, err := soldQuantity(tx, itemID)
+		if err != nil {
+			return err
+		}
+		if *edit.Quantity < sold {
+			return fmt.Errorf("%d of this item were already sold, the quantity can't be lower", sold)
+		}
+
+		if _, err := tx.Exec("UPDATE items SET quantity = ?, updated_at = ? WHERE id = ?", *edit.Quantity, now, itemID); err != nil {
+			return err
+		}
+
+		// Lowering the quantity to what was sold completes the item
+		if err := refreshItemSales(tx, itemID, now); err != nil {
+			return err
+		}
+	}
+
+	if edit.SellerID != nil {
+		// Clean up user ID
+		sellerID := strings.TrimPrefix(*edit.SellerID, "<@")
+		sellerID = strings.TrimPrefix(sellerID, "!")
+		sellerID = strings.TrimSuffix(sellerID, ">")
+
+		if _, err := tx.Exec("UPDATE items SET assigned_to = ?, updated_at = ? WHERE id = ?", sellerID, now, itemID); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT OR IGNORE INTO participants (guild_id, item_id, user_id) VALUES (?, ?, ?)",
+			guildID, itemID, sellerID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	action := AuditEdit
+	if edit.SellerID != nil {
+		action = AuditAssign
+	}
+	if err := recordOverride(tx, guildID, actor, itemID, ""); err != nil {
+		return err
+	}
+
+	if err := auditItem(tx, guildID, actor, action, itemID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,422 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kinds of ledger accounts. Every journal entry moves orbs between accounts, so its lines sum to zero.
+const (
+	AccountUser     = "user"     // what a member earned from sales, by user ID
+	AccountEscrow   = "escrow"   // what a seller still holds for other members, by seller ID; negative while they owe it
+	AccountBank     = "bank"     // the guild bank
+	AccountExternal = "external" // outside the guild's books: orbs paid in by buyers and taken in hand by members
+)
+
+// Kinds of journal entries
+const (
+	JournalSale       = "sale"       // the shares and commission of a sale
+	JournalTax        = "tax"        // the bank tax of a sale; negative when the sale was reversed
+	JournalReversal   = "reversal"   // the compensation of the shares of a reversed sale
+	JournalPayout     = "payout"     // a seller handing shares over
+	JournalSettlement = "settlement" // the shares cleared by a completed settlement
+	JournalDeposit    = "deposit"    // a member donating orbs to the bank
+	JournalWithdrawal = "withdrawal" // orbs paid out of the bank
+)
+
+// Account is a ledger account. The bank and the outside have no ID.
+type Account struct {
+	Kind string
+	ID   string
+}
+
+// String returns the account as kind:id, e.g. "escrow:1234"
+func (a Account) String() string {
+	if a.ID == "" {
+		return a.Kind
+	}
+	return a.Kind + ":" + a.ID
+}
+
+// JournalLine is one leg of a journal entry: orbs moved into (positive) or out of (negative) an account
+type JournalLine struct {
+	Account Account
+	Amount  int64
+}
+
+// AccountBalance is the balance of a ledger account
+type AccountBalance struct {
+	Account     Account
+	Balance     int64
+	LastEntryAt time.Time
+}
+
+// BalanceMismatch is an account whose balance in the journal differs from another source
+type BalanceMismatch struct {
+	Account Account
+	Journal int64 // balance replayed from the journal
+	Other   int64 // balance from the other source
+}
+
+// LedgerCheck is the outcome of replaying the journal of a guild
+type LedgerCheck struct {
+	Entries    int64
+	Accounts   int
+	Unbalanced []int64           // entries whose lines don't sum to zero
+	Mismatches []BalanceMismatch // accounts that disagree with the profit history, the shares sellers hold or the bank ledger
+	Corrected  []BalanceMismatch // stored balances that drifted from the journal and were rewritten
+}
+
+// Consistent reports whether every entry balances and every account agrees with the aggregates
+func (c *LedgerCheck) Consistent() bool {
+	return len(c.Unbalanced) == 0 && len(c.Mismatches) == 0
+}
+
+// GetAccountBalances retrieves the balances of a guild's accounts of one kind, highest first
+func GetAccountBalances(guildID string, kind string) ([]AccountBalance, error) {
+	rows, err := db.Query(`
+		SELECT account_kind, account_id, balance, last_entry_at
+		FROM ledger_balances
+		WHERE guild_id = ? AND account_kind = ?
+		ORDER BY balance DESC, account_id
+	`, guildID, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []AccountBalance
+	for rows.Next() {
+		var b AccountBalance
+		if err := rows.Scan(&b.Account.Kind, &b.Account.ID, &b.Balance, &b.LastEntryAt); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+
+	return balances, rows.Err()
+}
+
+// RebuildLedger replays a guild's journal to regenerate its account balances and checks them against
+// the profit history, the shares sellers still hold and the bank ledger
+func RebuildLedger(guildID string) (*LedgerCheck, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	check, err := rebuildBalances(tx, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return check, nil
+}
+
+// rebuildBalances replays a guild's journal, rewrites its stored balances and reports what didn't add up
+func rebuildBalances(tx *sql.Tx, guildID string) (*LedgerCheck, error) {
+	rows, err := tx.Query(`
+		SELECT e.id, e.created_at, l.account_kind, l.account_id, l.amount
+		FROM journal_entries e
+		JOIN journal_lines l ON l.entry_id = e.id
+		WHERE e.guild_id = ?
+		ORDER BY e.id, l.id
+	`, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &LedgerCheck{}
+	balances := make(map[Account]*AccountBalance)
+	var entryID, entryTotal int64
+	for rows.Next() {
+		var id int64
+		var createdAt time.Time
+		var line JournalLine
+		if err := rows.Scan(&id, &createdAt, &line.Account.Kind, &line.Account.ID, &line.Amount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if id != entryID {
+			if entryID != 0 && entryTotal != 0 {
+				check.Unbalanced = append(check.Unbalanced, entryID)
+			}
+			entryID, entryTotal = id, 0
+			check.Entries++
+		}
+		entryTotal += line.Amount
+
+		balance, ok := balances[line.Account]
+		if !ok {
+			balance = &AccountBalance{Account: line.Account}
+			balances[line.Account] = balance
+		}
+		balance.Balance += line.Amount
+		balance.LastEntryAt = createdAt
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if entryID != 0 && entryTotal != 0 {
+		check.Unbalanced = append(check.Unbalanced, entryID)
+	}
+	check.Accounts = len(balances)
+
+	expected, err := ledgerAggregates(tx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	for account, amount := range expected {
+		if journal := journalBalance(balances, account); journal != amount {
+			check.Mismatches = append(check.Mismatches, BalanceMismatch{Account: account, Journal: journal, Other: amount})
+		}
+	}
+	for account, balance := range balances {
+		if _, ok := expected[account]; !ok && account.Kind != AccountExternal && balance.Balance != 0 {
+			check.Mismatches = append(check.Mismatches, BalanceMismatch{Account: account, Journal: balance.Balance})
+		}
+	}
+
+	stored, err := storedBalances(tx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	for account, amount := range stored {
+		if journal := journalBalance(balances, account); journal != amount {
+			check.Corrected = append(check.Corrected, BalanceMismatch{Account: account, Journal: journal, Other: amount})
+		}
+	}
+	for account, balance := range balances {
+		if _, ok := stored[account]; !ok {
+			check.Corrected = append(check.Corrected, BalanceMismatch{Account: account, Journal: balance.Balance})
+		}
+	}
+
+	sortMismatches(check.Mismatches)
+	sortMismatches(check.Corrected)
+
+	// The journal is the source of truth, so the stored balances are rewritten either way
+	if _, err := tx.Exec("DELETE FROM ledger_balances WHERE guild_id = ?", guildID); err != nil {
+		return nil, err
+	}
+	for _, balance := range balances {
+		_, err := tx.Exec(
+			"INSERT INTO ledger_balances (guild_id, account_kind, account_id, balance, last_entry_at) VALUES (?, ?, ?, ?, ?)",
+			guildID, balance.Account.Kind, balance.Account.ID, balance.Balance, balance.LastEntryAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return check, nil
+}
+
+// ledgerAggregates sums what the accounts of a guild should hold according to the tables the journal mirrors.
+// Accounts that should be empty are left out; the outside has no aggregate of its own.
+func ledgerAggregates(tx *sql.Tx, guildID string) (map[Account]int64, error) {
+	expected := make(map[Account]int64)
+
+	// Members earned every share and commission recorded for them, reversals included
+	rows, err := tx.Query(`
+		SELECT user_id, SUM(amount) FROM profit_history
+		WHERE guild_id = ? AND user_id != ?
+		GROUP BY user_id
+	`, guildID, BankUserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := scanAggregates(rows, AccountUser, 1, expected); err != nil {
+		return nil, err
+	}
+
+	// Sellers hold the unpaid shares of others
+	rows, err = tx.Query(`
+		SELECT seller_id, SUM(amount) FROM profit_history
+		WHERE guild_id = ? AND status = ? AND user_id != seller_id AND user_id != ?
+		GROUP BY seller_id
+	`, guildID, ShareUnpaid, BankUserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := scanAggregates(rows, AccountEscrow, -1, expected); err != nil {
+		return nil, err
+	}
+
+	var bank int64
+	if err := tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM bank_ledger WHERE guild_id = ?", guildID).Scan(&bank); err != nil {
+		return nil, err
+	}
+	if bank != 0 {
+		expected[Account{Kind: AccountBank}] = bank
+	}
+
+	return expected, nil
+}
+
+// scanAggregates reads ID and sum pairs into accounts of one kind, multiplied by sign, skipping empty ones
+func scanAggregates(rows *sql.Rows, kind string, sign int64, expected map[Account]int64) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var amount int64
+		if err := rows.Scan(&id, &amount); err != nil {
+			return err
+		}
+		if amount != 0 {
+			expected[Account{Kind: kind, ID: id}] = sign * amount
+		}
+	}
+
+	return rows.Err()
+}
+
+// storedBalances reads the balances of a guild's accounts as they were kept up to date entry by entry
+func storedBalances(tx *sql.Tx, guildID string) (map[Account]int64, error) {
+	rows, err := tx.Query("SELECT account_kind, account_id, balance FROM ledger_balances WHERE guild_id = ?", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[Account]int64)
+	for rows.Next() {
+		var account Account
+		var balance int64
+		if err := rows.Scan(&account.Kind, &account.ID, &balance); err != nil {
+			return nil, err
+		}
+		stored[account] = balance
+	}
+
+	return stored, rows.Err()
+}
+
+// journalBalance returns the replayed balance of an account, 0 if the journal never touched it
+func journalBalance(balances map[Account]*AccountBalance, account Account) int64 {
+	if balance, ok := balances[account]; ok {
+		return balance.Balance
+	}
+	return 0
+}
+
+// sortMismatches orders mismatches by account so reports read the same every time
+func sortMismatches(mismatches []BalanceMismatch) {
+	sort.Slice(mismatches, func(a, b int) bool {
+		return mismatches[a].Account.String() < mismatches[b].Account.String()
+	})
+}
+
+// postJournal appends a journal entry and updates the balances of its accounts.
+// itemID and saleID are 0 if the entry isn't about an item or a sale. Empty lines are dropped,
+// and an entry without lines isn't posted at all.
+func postJournal(tx *sql.Tx, guildID string, kind string, itemID int64, saleID int64, memo string, lines []JournalLine, now time.Time) error {
+	var total int64
+	var posted []JournalLine
+	for _, line := range lines {
+		total += line.Amount
+		if line.Amount != 0 {
+			posted = append(posted, line)
+		}
+	}
+	if total != 0 {
+		return fmt.Errorf("%s journal entry doesn't balance: its lines sum to %d", kind, total)
+	}
+	if len(posted) == 0 {
+		return nil
+	}
+
+	var item, sale any
+	if itemID != 0 {
+		item = itemID
+	}
+	if saleID != 0 {
+		sale = saleID
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO journal_entries (guild_id, kind, item_id, sale_id, memo, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		guildID, kind, item, sale, memo, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	entryID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, line := range posted {
+		_, err := tx.Exec(
+			"INSERT INTO journal_lines (entry_id, account_kind, account_id, amount) VALUES (?, ?, ?, ?)",
+			entryID, line.Account.Kind, line.Account.ID, line.Amount,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO ledger_balances (guild_id, account_kind, account_id, balance, last_entry_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(guild_id, account_kind, account_id) DO UPDATE SET
+				balance = balance + excluded.balance, last_entry_at = excluded.last_entry_at
+		`, guildID, line.Account.Kind, line.Account.ID, line.Amount, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// heldInEscrow reports whether the seller holds a share until they hand it over.
+// Sellers keep their own shares and pay the tax into the bank on the spot.
+func heldInEscrow(userID string, sellerID string) bool {
+	return userID != sellerID && userID != BankUserID
+}
+
+// shareLines posts a profit history record as it is recorded: the member earns it, and it is either
+// held by the seller or already settled. Tax goes to the bank; negative amounts compensate a reversal.
+func shareLines(userID string, sellerID string, amount int64) []JournalLine {
+	if userID == BankUserID {
+		return bankLines(amount)
+	}
+
+	counter := Account{Kind: AccountExternal}
+	if heldInEscrow(userID, sellerID) {
+		counter = Account{Kind: AccountEscrow, ID: sellerID}
+	}
+	return []JournalLine{
+		{Account: Account{Kind: AccountUser, ID: userID}, Amount: amount},
+		{Account: counter, Amount: -amount},
+	}
+}
+
+// payoutLines posts a seller handing a share over, or nil if the seller never held it
+func payoutLines(userID string, sellerID string, amount int64) []JournalLine {
+	if !heldInEscrow(userID, sellerID) {
+		return nil
+	}
+	return []JournalLine{
+		{Account: Account{Kind: AccountEscrow, ID: sellerID}, Amount: amount},
+		{Account: Account{Kind: AccountExternal}, Amount: -amount},
+	}
+}
+
+// bankLines posts orbs paid into the bank, or out of it if amount is negative
+func bankLines(amount int64) []JournalLine {
+	return []JournalLine{
+		{Account: Account{Kind: AccountBank}, Amount: amount},
+		{Account: Account{Kind: AccountExternal}, Amount: -amount},
+	}
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/intinig/dr-peste/prices"
+)
+
+// marketSnapshotsKept is how many market snapshots are kept before the oldest are pruned
+const marketSnapshotsKept = 5
+
+// MarketSnapshot is a set of market prices imported at the same time
+type MarketSnapshot struct {
+	ID         int64
+	Source     string
+	ItemCount  int
+	ImportedAt time.Time
+}
+
+// MarketPrice is the price of a single unit of an item in an imported market snapshot
+type MarketPrice struct {
+	ItemName   string
+	UnitPrice  float64 // in the base currency
+	SnapshotID int64
+	ImportedAt time.Time
+}
+
+// SaveMarketSnapshot stores imported market prices as a new snapshot and prunes old snapshots
+func SaveMarketSnapshot(source string, marketPrices []prices.MarketPrice) (*MarketSnapshot, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(
+		"INSERT INTO market_snapshots (source, item_count, imported_at) VALUES (?, ?, ?)",
+		source, len(marketPrices), now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO market_prices (snapshot_id, item_name, unit_price) VALUES (?, ?, ?)")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for _, price := range marketPrices {
+		if _, err := stmt.Exec(snapshotID, strings.TrimSpace(price.Name), price.UnitPrice); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only the latest snapshots are ever read
+	_, err = tx.Exec(`
+		DELETE FROM market_prices WHERE snapshot_id NOT IN (
+			SELECT id FROM market_snapshots ORDER BY id DESC LIMIT ?
+		)`, marketSnapshotsKept)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		DELETE FROM market_snapshots WHERE id NOT IN (
+			SELECT id FROM market_snapshots ORDER BY id DESC LIMIT ?
+		)`, marketSnapshotsKept)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &MarketSnapshot{
+		ID:         snapshotID,
+		Source:     source,
+		ItemCount:  len(marketPrices),
+		ImportedAt: now,
+	}, nil
+}
+
+// GetMarketPrice returns the most recently imported market price of an item, or nil if no snapshot has it.
+// Item names are matched case-insensitively.
+func GetMarketPrice(itemName string) (*MarketPrice, error) {
+	price := &MarketPrice{}
+	err := db.QueryRow(`
+		SELECT mp.item_name, mp.unit_price, ms.id, ms.imported_at
+		FROM market_prices mp
+		JOIN market_snapshots ms ON mp.snapshot_id = ms.id
+		WHERE mp.item_name = ?
+		ORDER BY ms.id DESC
+		LIMIT 1
+	`, strings.TrimSpace(itemName)).Scan(&price.ItemName, &price.UnitPrice, &price.SnapshotID, &price.ImportedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return price, nil
+}
+
+// GetLatestMarketSnapshot returns the most recently imported market snapshot, or nil if none was imported
+func GetLatestMarketSnapshot() (*MarketSnapshot, error) {
+	snapshot := &MarketSnapshot{}
+	err := db.QueryRow(`
+		SELECT id, source, item_count, imported_at
+		FROM market_snapshots
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&snapshot.ID, &snapshot.Source, &snapshot.ItemCount, &snapshot.ImportedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return snapshot, nil
+}
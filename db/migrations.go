@@ -0,0 +1,947 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// migration is a single numbered schema change
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in the order it must be applied.
+// Versions must be strictly increasing; never edit a migration once it has shipped.
+var migrations = []migration{
+	{1, "create initial tables", createInitialTables},
+	{2, "split item quantity from estimated value", splitItemQuantity},
+	{3, "scope ledger tables by guild", addGuildScope},
+	{4, "add currency rates and sale currency", addCurrencies},
+	{5, "track payout state of shares", addSharePayouts},
+	{6, "add settlement plans", addSettlements},
+	{7, "add item cancellation and sale reversal", addReversals},
+	{8, "add participant weights", addParticipantWeights},
+	{9, "add guild config with seller commission and bank tax", addGuildConfig},
+	{10, "add guild bank ledger", addBankLedger},
+	{11, "add remainder strategies and awards", addRemainderAwards},
+	{12, "record sales separately to allow partial stack sales", addSales},
+	{13, "add price observations", addPriceObservations},
+	{14, "add market price snapshots", addMarketSnapshots},
+	{15, "add item details parsed from pasted item text", addItemDetails},
+	{16, "add item catalog, aliases and unmatched names", addItemCatalog},
+	{17, "add guild name aliases for merged item names", addNameAliases},
+	{18, "add farming sessions", addSessions},
+	{19, "add guild roles and admin overrides", addGuildRoles},
+	{20, "add audit events", addAuditEvents},
+	{21, "add double-entry journal and account balances", addJournal},
+	{22, "keep price observations of reversed sales", addObservationReversals},
+}
+
+// migrate applies all pending migrations, each inside its own transaction
+func migrate() error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	current, err := SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d), refusing to start", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+
+		log.Printf("Applied migration %d: %s", m.version, m.description)
+	}
+
+	return nil
+}
+
+// applyMigration runs a single migration and records it in schema_migrations
+func applyMigration(m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)",
+		m.version, m.description, time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SchemaVersion returns the latest migration version applied to the database
+func SchemaVersion() (int, error) {
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// LatestSchemaVersion returns the schema version this binary migrates to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// createInitialTables creates the tables of the original schema.
+// Databases created before migrations existed already have them, so every
+// statement must stay idempotent.
+func createInitialTables(tx *sql.Tx) error {
+	// Items table
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			estimated_value INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			assigned_to TEXT,
+			sale_amount INTEGER,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Participants table
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS participants (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			item_id INTEGER NOT NULL,
+			user_id TEXT NOT NULL,
+			share_amount INTEGER,
+			FOREIGN KEY (item_id) REFERENCES items(id),
+			UNIQUE(item_id, user_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Profit history table
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS profit_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			item_id INTEGER NOT NULL,
+			amount INTEGER NOT NULL,
+			transaction_date TIMESTAMP NOT NULL,
+			FOREIGN KEY (item_id) REFERENCES items(id)
+		)
+	`)
+	return err
+}
+
+// splitItemQuantity moves the quantity out of the estimated_value column.
+// Older versions stored the number of items dropped in estimated_value, so
+// existing rows get their quantity moved over and an unknown (zero) value.
+func splitItemQuantity(tx *sql.Tx) error {
+	// Databases upgraded by the pre-migration startup check already have the column
+	hasQuantity, err := columnExists(tx, "items", "quantity")
+	if err != nil || hasQuantity {
+		return err
+	}
+
+	_, err = tx.Exec("ALTER TABLE items ADD COLUMN quantity INTEGER NOT NULL DEFAULT 1")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE items SET quantity = estimated_value, estimated_value = 0")
+	return err
+}
+
+// addGuildScope adds a guild_id column to every ledger table.
+// Existing rows get an empty guild ID until they are claimed with ClaimUnscopedData.
+func addGuildScope(tx *sql.Tx) error {
+	for _, table := range []string{"items", "participants", "profit_history"} {
+		_, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN guild_id TEXT NOT NULL DEFAULT ''", table))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_items_guild ON items(guild_id, created_at)")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("CREATE INDEX IF NOT EXISTS idx_profit_history_guild ON profit_history(guild_id, user_id)")
+	return err
+}
+
+// addCurrencies creates the conversion rate table and records the currency of each sale.
+// Every sale made before this migration was recorded in Exalted Orbs.
+func addCurrencies(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS currency_rates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			currency TEXT NOT NULL,
+			rate REAL NOT NULL,
+			set_by TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("CREATE INDEX IF NOT EXISTS idx_currency_rates_guild ON currency_rates(guild_id, currency, created_at)")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("ALTER TABLE items ADD COLUMN sale_currency TEXT")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("ALTER TABLE items ADD COLUMN sale_original_amount INTEGER")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE items SET sale_currency = 'exalted', sale_original_amount = sale_amount
+		WHERE sale_amount IS NOT NULL
+	`)
+	return err
+}
+
+// addSharePayouts records who holds each share and whether it was handed over.
+// Shares distributed before this migration are assumed to have been paid out.
+func addSharePayouts(tx *sql.Tx) error {
+	statements := []string{
+		"ALTER TABLE profit_history ADD COLUMN seller_id TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE profit_history ADD COLUMN status TEXT NOT NULL DEFAULT 'unpaid'",
+		"ALTER TABLE profit_history ADD COLUMN paid_at TIMESTAMP",
+		"ALTER TABLE profit_history ADD COLUMN confirmed_at TIMESTAMP",
+		`UPDATE profit_history SET
+			seller_id = COALESCE((SELECT assigned_to FROM items WHERE items.id = profit_history.item_id), ''),
+			status = 'paid',
+			paid_at = transaction_date`,
+		"CREATE INDEX IF NOT EXISTS idx_profit_history_status ON profit_history(guild_id, status)",
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addSettlements creates the tables for netted settlement plans
+func addSettlements(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS settlements (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			created_by TEXT NOT NULL,
+			status TEXT NOT NULL,
+			share_count INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			completed_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS settlement_transfers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			settlement_id INTEGER NOT NULL,
+			from_user TEXT NOT NULL,
+			to_user TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			confirmed_at TIMESTAMP,
+			FOREIGN KEY (settlement_id) REFERENCES settlements(id)
+		)`,
+		"ALTER TABLE profit_history ADD COLUMN settlement_id INTEGER REFERENCES settlements(id)",
+		"CREATE INDEX IF NOT EXISTS idx_settlements_guild ON settlements(guild_id, status)",
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addReversals creates the reversal audit trail and links compensating shares to the originals
+func addReversals(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS item_reversals (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			item_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			actor_id TEXT NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			sale_amount INTEGER,
+			sale_currency TEXT,
+			sale_original_amount INTEGER,
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (item_id) REFERENCES items(id)
+		)`,
+		"ALTER TABLE profit_history ADD COLUMN reverses_id INTEGER REFERENCES profit_history(id)",
+		"CREATE INDEX IF NOT EXISTS idx_item_reversals_item ON item_reversals(guild_id, item_id)",
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addParticipantWeights lets participants take a larger or smaller cut of a sale
+func addParticipantWeights(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE participants ADD COLUMN weight REAL NOT NULL DEFAULT 1")
+	return err
+}
+
+// addGuildConfig stores per-guild sale settings and tells shares apart from commission and tax
+func addGuildConfig(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS guild_config (
+			guild_id TEXT PRIMARY KEY,
+			commission_pct REAL NOT NULL DEFAULT 0,
+			bank_tax_pct REAL NOT NULL DEFAULT 0,
+			updated_by TEXT,
+			updated_at TIMESTAMP
+		)`,
+		"ALTER TABLE profit_history ADD COLUMN kind TEXT NOT NULL DEFAULT 'share'",
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addBankLedger creates the guild bank ledger and carries over the taxes collected so far
+func addBankLedger(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS bank_ledger (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			user_id TEXT NOT NULL,
+			authorized_by TEXT,
+			item_id INTEGER,
+			note TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (item_id) REFERENCES items(id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_bank_ledger_guild ON bank_ledger(guild_id, created_at)",
+		`INSERT INTO bank_ledger (guild_id, kind, amount, user_id, item_id, created_at)
+			SELECT guild_id, 'tax', amount, seller_id, item_id, transaction_date
+			FROM profit_history WHERE user_id = 'bank'
+			ORDER BY id`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addRemainderAwards makes the remainder strategy configurable and keeps every leftover orb on record
+func addRemainderAwards(tx *sql.Tx) error {
+	statements := []string{
+		"ALTER TABLE guild_config ADD COLUMN remainder_strategy TEXT NOT NULL DEFAULT 'seller_first'",
+		`CREATE TABLE IF NOT EXISTS remainder_awards (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			item_id INTEGER NOT NULL,
+			user_id TEXT NOT NULL,
+			strategy TEXT NOT NULL,
+			seed INTEGER,
+			reason TEXT NOT NULL,
+			reversed INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (item_id) REFERENCES items(id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_remainder_awards_guild ON remainder_awards(guild_id, user_id)",
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addSales moves sales into their own table so a stack can be sold in several trades.
+// Sales made before this migration become a single sale of the item's whole quantity.
+func addSales(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS sales (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			item_id INTEGER NOT NULL,
+			quantity INTEGER NOT NULL,
+			amount INTEGER NOT NULL,
+			currency TEXT NOT NULL,
+			original_amount INTEGER NOT NULL,
+			seller_id TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'completed',
+			created_at TIMESTAMP NOT NULL,
+			reversed_at TIMESTAMP,
+			FOREIGN KEY (item_id) REFERENCES items(id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_sales_item ON sales(item_id, status)",
+		"ALTER TABLE profit_history ADD COLUMN sale_id INTEGER REFERENCES sales(id)",
+		"ALTER TABLE remainder_awards ADD COLUMN sale_id INTEGER REFERENCES sales(id)",
+		"ALTER TABLE bank_ledger ADD COLUMN sale_id INTEGER REFERENCES sales(id)",
+		"ALTER TABLE item_reversals ADD COLUMN sale_id INTEGER REFERENCES sales(id)",
+
+		// Sales that were reversed before partial sales existed
+		`INSERT INTO sales (guild_id, item_id, quantity, amount, currency, original_amount, seller_id, status, created_at, reversed_at)
+			SELECT r.guild_id, r.item_id, i.quantity, r.sale_amount, COALESCE(r.sale_currency, 'exalted'),
+				COALESCE(r.sale_original_amount, r.sale_amount), COALESCE(i.assigned_to, ''), 'reversed', r.created_at, r.created_at
+			FROM item_reversals r JOIN items i ON r.item_id = i.id
+			WHERE r.action = 'unsell' AND r.sale_amount IS NOT NULL
+			ORDER BY r.id`,
+		`UPDATE item_reversals SET sale_id = (
+			SELECT s.id FROM sales s
+			WHERE s.item_id = item_reversals.item_id AND s.reversed_at = item_reversals.created_at
+		) WHERE action = 'unsell'`,
+
+		// Sales that still stand
+		`INSERT INTO sales (guild_id, item_id, quantity, amount, currency, original_amount, seller_id, status, created_at)
+			SELECT guild_id, id, quantity, sale_amount, COALESCE(sale_currency, 'exalted'),
+				COALESCE(sale_original_amount, sale_amount), COALESCE(assigned_to, ''), 'completed', updated_at
+			FROM items
+			WHERE status IN ('sold', 'distributed') AND sale_amount IS NOT NULL
+			ORDER BY id`,
+
+		// Only the rows of a standing sale can be told apart: they were neither reversed nor compensate anything
+		`UPDATE profit_history SET sale_id = (
+			SELECT s.id FROM sales s WHERE s.item_id = profit_history.item_id AND s.status = 'completed'
+		)
+		WHERE reverses_id IS NULL AND status != 'reversed'
+			AND id NOT IN (SELECT reverses_id FROM profit_history WHERE reverses_id IS NOT NULL)`,
+		`UPDATE remainder_awards SET sale_id = (
+			SELECT s.id FROM sales s WHERE s.item_id = remainder_awards.item_id AND s.status = 'completed'
+		) WHERE reversed = 0`,
+		`UPDATE bank_ledger SET sale_id = (
+			SELECT p.sale_id FROM profit_history p
+			WHERE p.item_id = bank_ledger.item_id AND p.kind = 'tax' AND p.sale_id IS NOT NULL
+				AND p.transaction_date = bank_ledger.created_at
+		) WHERE kind = 'tax' AND amount > 0`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addPriceObservations adds the price history of items, filled from sales and manual price checks.
+// Standing sales made before this migration become observations.
+func addPriceObservations(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS price_observations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			item_name TEXT NOT NULL,
+			unit_price REAL NOT NULL,
+			quantity INTEGER NOT NULL DEFAULT 1,
+			source TEXT NOT NULL,
+			sale_id INTEGER REFERENCES sales(id),
+			observed_by TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_price_observations_item ON price_observations(guild_id, item_name, created_at)",
+		`INSERT INTO price_observations (guild_id, item_name, unit_price, quantity, source, sale_id, observed_by, created_at)
+			SELECT s.guild_id, i.name, CAST(s.amount AS REAL) / s.quantity, s.quantity, 'sale', s.id, s.seller_id, s.created_at
+			FROM sales s JOIN items i ON s.item_id = i.id
+			WHERE s.status = 'completed' AND s.quantity > 0
+			ORDER BY s.id`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addMarketSnapshots adds imported market prices, used when a guild has no prices of its own for an item.
+// Snapshots are shared by every guild since the market is.
+func addMarketSnapshots(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS market_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source TEXT NOT NULL,
+			item_count INTEGER NOT NULL,
+			imported_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS market_prices (
+			snapshot_id INTEGER NOT NULL,
+			item_name TEXT NOT NULL COLLATE NOCASE,
+			unit_price REAL NOT NULL,
+			PRIMARY KEY (snapshot_id, item_name),
+			FOREIGN KEY (snapshot_id) REFERENCES market_snapshots(id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_market_prices_item ON market_prices(item_name)",
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addItemDetails adds what was read from the in-game item text of items that were pasted
+func addItemDetails(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS item_details (
+			item_id INTEGER PRIMARY KEY,
+			item_class TEXT NOT NULL,
+			rarity TEXT NOT NULL,
+			base_type TEXT NOT NULL,
+			item_level INTEGER NOT NULL DEFAULT 0,
+			corrupted INTEGER NOT NULL DEFAULT 0,
+			mods TEXT NOT NULL DEFAULT '',
+			raw_text TEXT NOT NULL,
+			FOREIGN KEY (item_id) REFERENCES items(id)
+		)
+	`)
+	return err
+}
+
+// addItemCatalog adds the canonical item names, the aliases they are known by, and the
+// names typed in that matched neither so an admin can merge them
+func addItemCatalog(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS item_catalog (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+			category TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS item_aliases (
+			alias TEXT PRIMARY KEY,
+			catalog_id INTEGER NOT NULL,
+			FOREIGN KEY (catalog_id) REFERENCES item_catalog(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS unmatched_names (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			name TEXT NOT NULL COLLATE NOCASE,
+			suggestion TEXT NOT NULL DEFAULT '',
+			seen_count INTEGER NOT NULL DEFAULT 1,
+			first_seen_at TIMESTAMP NOT NULL,
+			last_seen_at TIMESTAMP NOT NULL,
+			UNIQUE (guild_id, name)
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addNameAliases adds the item names an admin merged into another, so they resolve to it from then on
+func addNameAliases(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS name_aliases (
+			guild_id TEXT NOT NULL,
+			alias TEXT NOT NULL,
+			name TEXT NOT NULL,
+			created_by TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (guild_id, alias)
+		)
+	`)
+	return err
+}
+
+// addSessions adds farming sessions, which group the drops of a party in a channel
+func addSessions(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			created_by TEXT NOT NULL,
+			status TEXT NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			ended_at TIMESTAMP
+		)`,
+		// A channel can only have one session running at a time
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_active ON sessions(guild_id, channel_id) WHERE status = 'active'",
+		`CREATE TABLE IF NOT EXISTS session_participants (
+			session_id INTEGER NOT NULL,
+			user_id TEXT NOT NULL,
+			PRIMARY KEY (session_id, user_id),
+			FOREIGN KEY (session_id) REFERENCES sessions(id)
+		)`,
+		"ALTER TABLE items ADD COLUMN session_id INTEGER REFERENCES sessions(id)",
+		"CREATE INDEX IF NOT EXISTS idx_items_session ON items(session_id)",
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addGuildRoles adds the mapping of Discord roles to bot roles and the log of admins acting on items they don't sell
+func addGuildRoles(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS guild_roles (
+			guild_id TEXT NOT NULL,
+			role_id TEXT NOT NULL,
+			bot_role TEXT NOT NULL,
+			set_by TEXT NOT NULL,
+			set_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (guild_id, role_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS admin_overrides (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			action TEXT NOT NULL,
+			item_id INTEGER,
+			detail TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (item_id) REFERENCES items(id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_admin_overrides_item ON admin_overrides(guild_id, item_id)",
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addAuditEvents adds the append-only log of every change to the ledger
+func addAuditEvents(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS audit_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			actor_id TEXT NOT NULL,
+			action TEXT NOT NULL,
+			item_id INTEGER,
+			before_json TEXT,
+			after_json TEXT,
+			interaction_id TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (item_id) REFERENCES items(id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_audit_events_item ON audit_events(guild_id, item_id)",
+		// Evidence is only worth something if it can't be rewritten
+		`CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+		BEGIN
+			SELECT RAISE(ABORT, 'audit events are append-only');
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+		BEGIN
+			SELECT RAISE(ABORT, 'audit events are append-only');
+		END`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addJournal adds the double-entry journal every balance is derived from and replays the history recorded so far into it
+func addJournal(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS journal_entries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			item_id INTEGER,
+			sale_id INTEGER,
+			memo TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (item_id) REFERENCES items(id),
+			FOREIGN KEY (sale_id) REFERENCES sales(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS journal_lines (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			entry_id INTEGER NOT NULL,
+			account_kind TEXT NOT NULL,
+			account_id TEXT NOT NULL DEFAULT '',
+			amount INTEGER NOT NULL,
+			FOREIGN KEY (entry_id) REFERENCES journal_entries(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS ledger_balances (
+			guild_id TEXT NOT NULL,
+			account_kind TEXT NOT NULL,
+			account_id TEXT NOT NULL DEFAULT '',
+			balance INTEGER NOT NULL,
+			last_entry_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (guild_id, account_kind, account_id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_journal_entries_guild ON journal_entries(guild_id)",
+		"CREATE INDEX IF NOT EXISTS idx_journal_lines_entry ON journal_lines(entry_id)",
+		// Balances are rebuilt by replaying the journal, so it must never change.
+		// Entries carried over from before multi-guild support can still be claimed by a guild once.
+		`CREATE TRIGGER IF NOT EXISTS journal_entries_no_update BEFORE UPDATE ON journal_entries
+		WHEN OLD.guild_id != ''
+		BEGIN
+			SELECT RAISE(ABORT, 'journal entries are append-only');
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS journal_entries_no_delete BEFORE DELETE ON journal_entries
+		BEGIN
+			SELECT RAISE(ABORT, 'journal entries are append-only');
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS journal_lines_no_update BEFORE UPDATE ON journal_lines
+		BEGIN
+			SELECT RAISE(ABORT, 'journal lines are append-only');
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS journal_lines_no_delete BEFORE DELETE ON journal_lines
+		BEGIN
+			SELECT RAISE(ABORT, 'journal lines are append-only');
+		END`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return backfillJournal(tx)
+}
+
+// backfillJournal posts the shares and bank movements recorded before the journal existed, oldest first.
+// Shares are posted when they were recorded and, once handed over, again when they were paid;
+// the records of a sale recorded together make up one entry.
+func backfillJournal(tx *sql.Tx) error {
+	type posting struct {
+		guildID string
+		kind    string
+		itemID  int64
+		saleID  int64
+		memo    string
+		at      time.Time
+		lines   []JournalLine
+	}
+	var postings []*posting
+	grouped := make(map[string]*posting)
+	post := func(guildID string, kind string, itemID int64, saleID int64, memo string, at time.Time, lines []JournalLine) {
+		key := fmt.Sprintf("%s|%s|%d|%d|%s|%s", guildID, kind, itemID, saleID, memo, at.Format(time.RFC3339Nano))
+		if p, ok := grouped[key]; ok {
+			p.lines = append(p.lines, lines...)
+			return
+		}
+		p := &posting{guildID: guildID, kind: kind, itemID: itemID, saleID: saleID, memo: memo, at: at, lines: lines}
+		grouped[key] = p
+		postings = append(postings, p)
+	}
+
+	rows, err := tx.Query(`
+		SELECT guild_id, user_id, seller_id, item_id, COALESCE(sale_id, 0), amount, status, paid_at, transaction_date,
+			reverses_id IS NOT NULL, COALESCE(settlement_id, 0)
+		FROM profit_history
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var guildID, userID, sellerID, status string
+		var itemID, saleID, amount, settlementID int64
+		var paidAt sql.NullTime
+		var recordedAt time.Time
+		var reverses bool
+		if err := rows.Scan(&guildID, &userID, &sellerID, &itemID, &saleID, &amount, &status, &paidAt, &recordedAt, &reverses, &settlementID); err != nil {
+			rows.Close()
+			return err
+		}
+
+		kind, memo := JournalSale, ""
+		switch {
+		case userID == BankUserID && reverses:
+			kind, memo = JournalTax, "sale reversed"
+		case userID == BankUserID:
+			kind = JournalTax
+		case reverses:
+			kind = JournalReversal
+		}
+		post(guildID, kind, itemID, saleID, memo, recordedAt, shareLines(userID, sellerID, amount))
+
+		if status != SharePaid && status != ShareConfirmed {
+			continue
+		}
+		lines := payoutLines(userID, sellerID, amount)
+		if lines == nil {
+			continue
+		}
+		if paidAt.Valid {
+			recordedAt = paidAt.Time
+		}
+		if settlementID != 0 {
+			post(guildID, JournalSettlement, 0, 0, fmt.Sprintf("settlement #%d", settlementID), recordedAt, lines)
+		} else {
+			post(guildID, JournalPayout, itemID, 0, "", recordedAt, lines)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Taxes are already posted from the profit history; donations and withdrawals only live in the bank ledger
+	rows, err = tx.Query(`
+		SELECT guild_id, kind, amount, note, created_at
+		FROM bank_ledger
+		WHERE kind IN (?, ?)
+		ORDER BY id
+	`, BankDeposit, BankWithdrawal)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var guildID, kind, note string
+		var amount int64
+		var createdAt time.Time
+		if err := rows.Scan(&guildID, &kind, &amount, &note, &createdAt); err != nil {
+			rows.Close()
+			return err
+		}
+
+		journalKind := JournalDeposit
+		if kind == BankWithdrawal {
+			journalKind = JournalWithdrawal
+		}
+		post(guildID, journalKind, 0, 0, note, createdAt, bankLines(amount))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sort.SliceStable(postings, func(a, b int) bool {
+		return postings[a].at.Before(postings[b].at)
+	})
+	for _, p := range postings {
+		if err := postJournal(tx, p.guildID, p.kind, p.itemID, p.saleID, p.memo, p.lines, p.at); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// columnExists reports whether a table has a column with the given name
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid int
+		var name, colType string
+		var notNull, pk int
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// addObservationReversals marks the price observations of reversed sales instead of deleting them.
+// Observations that reversals already deleted are restored from their sales as reversed.
+func addObservationReversals(tx *sql.Tx) error {
+	statements := []string{
+		"ALTER TABLE price_observations ADD COLUMN reversed_at TIMESTAMP",
+		`INSERT INTO price_observations (guild_id, item_name, unit_price, quantity, source, sale_id, observed_by, created_at, reversed_at)
+			SELECT s.guild_id, i.name, CAST(s.amount AS REAL) / s.quantity, s.quantity, 'sale', s.id, s.seller_id, s.created_at, s.reversed_at
+			FROM sales s JOIN items i ON s.item_id = i.id
+			WHERE s.status = 'reversed' AND s.quantity > 0
+				AND NOT EXISTS (SELECT 1 FROM price_observations o WHERE o.sale_id = s.id)
+			ORDER BY s.id`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+)
+
+// baselineSchema is the schema databases had before migrations existed
+var baselineSchema = []string{
+	`CREATE TABLE items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		estimated_value INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		assigned_to TEXT,
+		sale_amount INTEGER,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE TABLE participants (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		item_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		share_amount INTEGER,
+		FOREIGN KEY (item_id) REFERENCES items(id),
+		UNIQUE(item_id, user_id)
+	)`,
+	`CREATE TABLE profit_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		item_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL,
+		transaction_date TIMESTAMP NOT NULL,
+		FOREIGN KEY (item_id) REFERENCES items(id)
+	)`,
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A database written by the original bot: one stack of three sold for 90 and split two ways, one still unsold
+	legacy, err := sql.Open("sqlite", "data/poe2bot.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range baselineSchema {
+		if _, err := legacy.Exec(statement); err != nil {
+			legacy.Close()
+			t.Fatalf("creating the baseline schema: %v", err)
+		}
+	}
+	now := time.Now()
+	rows := []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO items (id, name, estimated_value, status, assigned_to, sale_amount, created_at, updated_at) VALUES (1, 'Divine Orb', 3, 'distributed', 'a', 90, ?, ?)", []any{now, now}},
+		{"INSERT INTO items (id, name, estimated_value, status, assigned_to, created_at, updated_at) VALUES (2, 'Exalted Orb', 5, 'assigned', 'b', ?, ?)", []any{now, now}},
+		{"INSERT INTO participants (item_id, user_id, share_amount) VALUES (1, 'a', 45), (1, 'b', 45), (2, 'a', NULL), (2, 'b', NULL)", nil},
+		{"INSERT INTO profit_history (user_id, item_id, amount, transaction_date) VALUES ('a', 1, 45, ?), ('b', 1, 45, ?)", []any{now, now}},
+	}
+	for _, row := range rows {
+		if _, err := legacy.Exec(row.query, row.args...); err != nil {
+			legacy.Close()
+			t.Fatalf("filling the baseline database: %v", err)
+		}
+	}
+	legacy.Close()
+
+	if err := Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	t.Cleanup(Close)
+
+	if version, err := SchemaVersion(); err != nil || version != LatestSchemaVersion() {
+		t.Fatalf("SchemaVersion() = %d, %v, want %d", version, err, LatestSchemaVersion())
+	}
+
+	const guild = "guild"
+	claimed, err := ClaimUnscopedData(guild)
+	if err != nil {
+		t.Fatalf("ClaimUnscopedData() error = %v", err)
+	}
+	if claimed != 2 {
+		t.Errorf("ClaimUnscopedData() = %d, want both items", claimed)
+	}
+
+	item, err := GetItem(guild, 1)
+	if err != nil {
+		t.Fatalf("GetItem() error = %v", err)
+	}
+	if item.Quantity != 3 || item.EstimatedValue != 0 {
+		t.Errorf("migrated item has quantity %d and value %d, want the old estimated value as its quantity", item.Quantity, item.EstimatedValue)
+	}
+
+	// Shares handed out before payouts were tracked count as paid
+	debts, err := GetOutstandingDebts(guild)
+	if err != nil {
+		t.Fatalf("GetOutstandingDebts() error = %v", err)
+	}
+	if len(debts) != 0 {
+		t.Errorf("GetOutstandingDebts() = %v, want none", debts)
+	}
+
+	checkLedger(t, guild)
+}
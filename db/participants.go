@@ -0,0 +1,192 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// AddParticipant adds a member with the given share weight to the participants of an item that hasn't been distributed yet
+func AddParticipant(guildID string, itemID int64, userID string, weight float64, actor Actor) error {
+	if weight <= 0 {
+		return fmt.Errorf("weight must be greater than zero")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := participantItemSeller(tx, guildID, itemID); err != nil {
+		return err
+	}
+
+	before, err := loadItemState(tx, itemID)
+	if err != nil {
+		return err
+	}
+
+	// Clean up user ID
+	userID = strings.TrimPrefix(userID, "<@")
+	userID = strings.TrimPrefix(userID, "!")
+	userID = strings.TrimSuffix(userID, ">")
+
+	_, err = tx.Exec(
+		"INSERT INTO participants (guild_id, item_id, user_id, weight) VALUES (?, ?, ?, ?)",
+		guildID, itemID, userID, weight,
+	)
+	if err != nil {
+		// participants has UNIQUE(item_id, user_id)
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("<@%s> is already a participant of item #%d", userID, itemID)
+		}
+		return err
+	}
+
+	if err := recordOverride(tx, guildID, actor, itemID, ""); err != nil {
+		return err
+	}
+
+	if err := auditItem(tx, guildID, actor, AuditParticipantAdd, itemID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveParticipant removes a member from the participants of an item that hasn't been distributed yet.
+// The seller of the item and its last participant can't be removed.
+func RemoveParticipant(guildID string, itemID int64, userID string, actor Actor) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sellerID, err := participantItemSeller(tx, guildID, itemID)
+	if err != nil {
+		return err
+	}
+
+	before, err := loadItemState(tx, itemID)
+	if err != nil {
+		return err
+	}
+
+	// Clean up user ID
+	userID = strings.TrimPrefix(userID, "<@")
+	userID = strings.TrimPrefix(userID, "!")
+	userID = strings.TrimSuffix(userID, ">")
+
+	if userID == sellerID {
+		return fmt.Errorf("the seller can't be removed from the participants")
+	}
+
+	result, err := tx.Exec("DELETE FROM participants WHERE item_id = ? AND user_id = ?", itemID, userID)
+	if err != nil {
+		return err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("<@%s> is not a participant of item #%d", userID, itemID)
+	}
+
+	// Someone has to receive the proceeds once the item sells
+	var remaining int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM participants WHERE item_id = ?", itemID).Scan(&remaining); err != nil {
+		return err
+	}
+	if remaining == 0 {
+		return fmt.Errorf("the last participant of an item can't be removed")
+	}
+
+	if err := recordOverride(tx, guildID, actor, itemID, ""); err != nil {
+		return err
+	}
+
+	if err := auditItem(tx, guildID, actor, AuditParticipantRemove, itemID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetParticipantWeight changes the share weight of a participant of an item that hasn't been distributed yet
+func SetParticipantWeight(guildID string, itemID int64, userID string, weight float64, actor Actor) error {
+	if weight <= 0 {
+		return fmt.Errorf("weight must be greater than zero")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := participantItemSeller(tx, guildID, itemID); err != nil {
+		return err
+	}
+
+	before, err := loadItemState(tx, itemID)
+	if err != nil {
+		return err
+	}
+
+	// Clean up user ID
+	userID = strings.TrimPrefix(userID, "<@")
+	userID = strings.TrimPrefix(userID, "!")
+	userID = strings.TrimSuffix(userID, ">")
+
+	result, err := tx.Exec("UPDATE participants SET weight = ? WHERE item_id = ? AND user_id = ?", weight, itemID, userID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return fmt.Errorf("<@%s> is not a participant of item #%d", userID, itemID)
+	}
+
+	if err := recordOverride(tx, guildID, actor, itemID, ""); err != nil {
+		return err
+	}
+
+	if err := auditItem(tx, guildID, actor, AuditParticipantWeight, itemID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// participantItemSeller checks that the participants of an item can still change and returns its seller
+func participantItemSeller(tx *sql.Tx, guildID string, itemID int64) (string, error) {
+	var status string
+	var sellerID sql.NullString
+	err := tx.QueryRow(
+		"SELECT status, assigned_to FROM items WHERE id = ? AND guild_id = ?",
+		itemID, guildID,
+	).Scan(&status, &sellerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("item with ID %d not found", itemID)
+		}
+		return "", err
+	}
+
+	switch status {
+	case "distributed":
+		return "", fmt.Errorf("participants can't change after profits were distributed")
+	case "cancelled":
+		return "", fmt.Errorf("participants can't change on a cancelled item")
+	}
+
+	return sellerID.String, nil
+}
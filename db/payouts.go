@@ -0,0 +1,267 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Share payout states
+const (
+	ShareUnpaid    = "unpaid"    // the seller still holds the orbs
+	SharePaid      = "paid"      // the seller handed the orbs over
+	ShareConfirmed = "confirmed" // the participant confirmed receiving them
+)
+
+// Kinds of profit history records
+const (
+	KindShare      = "share"      // a participant's cut of the split
+	KindCommission = "commission" // the seller's commission, taken before the split
+	KindTax        = "tax"        // the guild bank tax, taken before the split
+)
+
+// profitRecordColumns is the column list scanned by scanProfitRecords.
+// Queries must alias profit_history as p and join items as i.
+const profitRecordColumns = `p.id, p.guild_id, p.user_id, p.seller_id, p.item_id, i.name, p.amount,
+		p.status, p.kind, p.paid_at, p.confirmed_at, p.transaction_date`
+
+// Debt is the amount a seller still owes a participant
+type Debt struct {
+	From   string // seller holding the orbs
+	To     string // participant owed the orbs
+	Amount int64
+}
+
+// scanProfitRecords reads profit records selected with profitRecordColumns
+func scanProfitRecords(rows *sql.Rows) ([]ProfitRecord, error) {
+	var records []ProfitRecord
+	for rows.Next() {
+		var record ProfitRecord
+		var paidAt, confirmedAt sql.NullTime
+		if err := rows.Scan(
+			&record.ID, &record.GuildID, &record.UserID, &record.SellerID, &record.ItemID, &record.ItemName,
+			&record.Amount, &record.Status, &record.Kind, &paidAt, &confirmedAt, &record.TransactionDate,
+		); err != nil {
+			return nil, err
+		}
+		record.PaidAt = paidAt.Time
+		record.ConfirmedAt = confirmedAt.Time
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// GetShare retrieves a single profit history record by ID
+func GetShare(guildID string, shareID int64) (*ProfitRecord, error) {
+	rows, err := db.Query(`
+		SELECT `+profitRecordColumns+`
+		FROM profit_history p
+		JOIN items i ON p.item_id = i.id
+		WHERE p.guild_id = ? AND p.id = ?
+	`, guildID, shareID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records, err := scanProfitRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("share with ID %d not found", shareID)
+	}
+
+	return &records[0], nil
+}
+
+// GetItemShares retrieves the profit history records of an item
+func GetItemShares(guildID string, itemID int64) ([]ProfitRecord, error) {
+	rows, err := db.Query(`
+		SELECT `+profitRecordColumns+`
+		FROM profit_history p
+		JOIN items i ON p.item_id = i.id
+		WHERE p.guild_id = ? AND p.item_id = ?
+		ORDER BY p.id
+	`, guildID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanProfitRecords(rows)
+}
+
+// GetSaleShares retrieves the profit history records of a single sale, including compensating rows
+func GetSaleShares(guildID string, saleID int64) ([]ProfitRecord, error) {
+	rows, err := db.Query(`
+		SELECT `+profitRecordColumns+`
+		FROM profit_history p
+		JOIN items i ON p.item_id = i.id
+		WHERE p.guild_id = ? AND p.sale_id = ?
+		ORDER BY p.id
+	`, guildID, saleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanProfitRecords(rows)
+}
+
+// MarkSharesPaid marks a seller's unpaid shares of an item as delivered.
+// If userID is empty every unpaid share of the item is marked. Shares reserved by an
+// open settlement and orbs owed back after a reversal are skipped. It returns the shares that changed.
+func MarkSharesPaid(guildID string, itemID int64, sellerID string, userID string, actor Actor) ([]ProfitRecord, error) {
+	// Clean up user ID (remove mentions if present)
+	userID = strings.TrimPrefix(userID, "<@")
+	userID = strings.TrimPrefix(userID, "!")
+	userID = strings.TrimSuffix(userID, ">")
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT ` + profitRecordColumns + `
+		FROM profit_history p
+		JOIN items i ON p.item_id = i.id
+		WHERE p.guild_id = ? AND p.item_id = ? AND p.seller_id = ? AND p.status = ? AND p.settlement_id IS NULL AND p.amount > 0`
+	args := []interface{}{guildID, itemID, sellerID, ShareUnpaid}
+	if userID != "" {
+		query += " AND p.user_id = ?"
+		args = append(args, userID)
+	}
+
+	rows, err := tx.Query(query+" ORDER BY p.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := scanProfitRecords(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := loadItemState(tx, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var lines []JournalLine
+	for idx := range shares {
+		_, err = tx.Exec(
+			"UPDATE profit_history SET status = ?, paid_at = ? WHERE id = ?",
+			SharePaid, now, shares[idx].ID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		shares[idx].Status = SharePaid
+		shares[idx].PaidAt = now
+		lines = append(lines, payoutLines(shares[idx].UserID, shares[idx].SellerID, shares[idx].Amount)...)
+	}
+
+	if err := postJournal(tx, guildID, JournalPayout, itemID, 0, "", lines, now); err != nil {
+		return nil, err
+	}
+
+	if len(shares) > 0 {
+		if err := auditItem(tx, guildID, actor, AuditPayout, itemID, before); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// ConfirmShare records that a participant received a paid share
+func ConfirmShare(guildID string, shareID int64, actor Actor) error {
+	share, err := GetShare(guildID, shareID)
+	if err != nil {
+		return err
+	}
+
+	if share.UserID != actor.UserID {
+		return fmt.Errorf("only the recipient can confirm this share")
+	}
+
+	switch share.Status {
+	case ShareUnpaid:
+		return fmt.Errorf("the seller has not marked this share as paid yet")
+	case ShareConfirmed:
+		return fmt.Errorf("this share was already confirmed")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE profit_history SET status = ?, confirmed_at = ? WHERE id = ? AND user_id = ? AND status = ?",
+		ShareConfirmed, time.Now(), shareID, actor.UserID, SharePaid,
+	)
+	if err != nil {
+		return err
+	}
+
+	// The share may have been confirmed or voided in the meantime
+	if confirmed, err := result.RowsAffected(); err != nil {
+		return err
+	} else if confirmed == 0 {
+		var status string
+		if err := tx.QueryRow("SELECT status FROM profit_history WHERE id = ?", shareID).Scan(&status); err != nil {
+			return err
+		}
+		if status == ShareConfirmed {
+			return fmt.Errorf("this share was already confirmed")
+		}
+		return fmt.Errorf("this share is no longer marked as paid")
+	}
+
+	before := map[string]any{"share": shareID, "amount": share.Amount, "status": share.Status}
+	after := map[string]any{"share": shareID, "amount": share.Amount, "status": ShareConfirmed}
+	if err := recordAudit(tx, guildID, actor, AuditConfirmShare, share.ItemID, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetOutstandingDebts sums the unpaid shares of a guild by seller and recipient
+func GetOutstandingDebts(guildID string) ([]Debt, error) {
+	rows, err := db.Query(`
+		SELECT seller_id, user_id, SUM(amount)
+		FROM profit_history
+		WHERE guild_id = ? AND status = ? AND seller_id != user_id
+		GROUP BY seller_id, user_id
+		HAVING SUM(amount) != 0
+		ORDER BY seller_id, user_id
+	`, guildID, ShareUnpaid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var debts []Debt
+	for rows.Next() {
+		var debt Debt
+		if err := rows.Scan(&debt.From, &debt.To, &debt.Amount); err != nil {
+			return nil, err
+		}
+		debts = append(debts, debt)
+	}
+
+	return debts, rows.Err()
+}
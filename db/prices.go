@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intinig/dr-peste/prices"
+)
+
+// Price observation sources
+const (
+	PriceFromSale  = "sale"
+	PriceFromCheck = "check"
+)
+
+// priceSampleLimit is how many recent observations of an item its price estimate looks at
+const priceSampleLimit = 20
+
+// PriceObservation is the price a single unit of an item went for, or was checked at
+type PriceObservation struct {
+	ID         int64
+	GuildID    string
+	ItemName   string
+	UnitPrice  float64 // in the base currency
+	Quantity   int64   // size of the stack the price was seen on
+	Source     string  // "sale", "check"
+	SaleID     int64   // sale the price comes from, 0 for price checks
+	ObservedBy string
+	CreatedAt  time.Time
+}
+
+// RecordPriceCheck records the unit price of an item seen outside of a sale, e.g. on the trade site
+func RecordPriceCheck(guildID string, itemName string, unitPrice float64, actor Actor) (*PriceObservation, error) {
+	itemName = strings.TrimSpace(itemName)
+	if itemName == "" {
+		return nil, fmt.Errorf("item name can't be empty")
+	}
+	if unitPrice <= 0 {
+		return nil, fmt.Errorf("price must be greater than zero")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		INSERT INTO price_observations (guild_id, item_name, unit_price, quantity, source, observed_by, created_at)
+		VALUES (?, ?, ?, 1, ?, ?, ?)
+	`, guildID, itemName, unitPrice, PriceFromCheck, actor.UserID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	after := map[string]any{"observation": id, "item_name": itemName, "unit_price": unitPrice}
+	if err := recordAudit(tx, guildID, actor, AuditPriceCheck, 0, nil, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &PriceObservation{
+		ID:         id,
+		GuildID:    guildID,
+		ItemName:   itemName,
+		UnitPrice:  unitPrice,
+		Quantity:   1,
+		Source:     PriceFromCheck,
+		ObservedBy: actor.UserID,
+		CreatedAt:  now,
+	}, nil
+}
+
+// GetPriceObservations retrieves the most recent price observations of an item, newest first.
+// Observations of reversed sales are left out.
+func GetPriceObservations(guildID string, itemName string, limit int) ([]PriceObservation, error) {
+	rows, err := db.Query(`
+		SELECT id, guild_id, item_name, unit_price, quantity, source, COALESCE(sale_id, 0), observed_by, created_at
+		FROM price_observations
+		WHERE guild_id = ? AND item_name = ? AND reversed_at IS NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, guildID, itemName, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var observations []PriceObservation
+	for rows.Next() {
+		var o PriceObservation
+		if err := rows.Scan(
+			&o.ID, &o.GuildID, &o.ItemName, &o.UnitPrice, &o.Quantity, &o.Source, &o.SaleID, &o.ObservedBy, &o.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		observations = append(observations, o)
+	}
+
+	return observations, rows.Err()
+}
+
+// GetPriceEstimate estimates the per-unit price of an item from its most recent sales and price checks.
+// Items the guild has no prices for fall back to the latest imported market price.
+// It returns false if no price was ever seen for the item.
+func GetPriceEstimate(guildID string, itemName string) (prices.Estimate, bool, error) {
+	observations, err := GetPriceObservations(guildID, itemName, priceSampleLimit)
+	if err != nil {
+		return prices.Estimate{}, false, err
+	}
+
+	if estimate, ok := prices.EstimateAt(PricePoints(observations), time.Now()); ok {
+		return estimate, true, nil
+	}
+
+	market, err := GetMarketPrice(itemName)
+	if err != nil || market == nil {
+		return prices.Estimate{}, false, err
+	}
+
+	return prices.Estimate{
+		UnitPrice: market.UnitPrice,
+		Samples:   1,
+		Oldest:    market.ImportedAt,
+		Newest:    market.ImportedAt,
+		Market:    true,
+	}, true, nil
+}
+
+// PricePoints turns price observations into the points the estimator works with
+func PricePoints(observations []PriceObservation) []prices.Observation {
+	points := make([]prices.Observation, 0, len(observations))
+	for _, o := range observations {
+		points = append(points, prices.Observation{UnitPrice: o.UnitPrice, At: o.CreatedAt})
+	}
+	return points
+}
+
+// recordSaleObservation adds the unit price of a sale to the price history of its item
+func recordSaleObservation(tx *sql.Tx, guildID string, itemID int64, saleID int64, amount int64, quantity int64, sellerID string, now time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO price_observations (guild_id, item_name, unit_price, quantity, source, sale_id, observed_by, created_at)
+		SELECT ?, name, ?, ?, ?, ?, ?, ? FROM items WHERE id = ?
+	`, guildID, float64(amount)/float64(quantity), quantity, PriceFromSale, saleID, sellerID, now, itemID)
+	return err
+}
+
+// renameSaleObservations moves the price history of an item's sales to its new name
+func renameSaleObservations(tx *sql.Tx, itemID int64, name string) error {
+	_, err := tx.Exec(
+		"UPDATE price_observations SET item_name = ? WHERE sale_id IN (SELECT id FROM sales WHERE item_id = ?)",
+		name, itemID,
+	)
+	return err
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CurrencyRate is a conversion rate to the base currency set by an admin
+type CurrencyRate struct {
+	ID        int64
+	GuildID   string
+	Currency  string
+	Rate      float64 // base currency per unit
+	SetBy     string
+	CreatedAt time.Time
+}
+
+// SetCurrencyRate records a new conversion rate for a guild.
+// Older rates are kept so past conversions can be audited.
+func SetCurrencyRate(guildID string, currency string, rate float64, actor Actor) error {
+	if rate <= 0 {
+		return fmt.Errorf("rate must be greater than zero")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before map[string]any
+	var previous float64
+	err = tx.QueryRow(
+		"SELECT rate FROM currency_rates WHERE guild_id = ? AND currency = ? ORDER BY created_at DESC, id DESC LIMIT 1",
+		guildID, currency,
+	).Scan(&previous)
+	switch {
+	case err == nil:
+		before = map[string]any{"currency": currency, "rate": previous}
+	case err != sql.ErrNoRows:
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO currency_rates (guild_id, currency, rate, set_by, created_at) VALUES (?, ?, ?, ?, ?)",
+		guildID, currency, rate, actor.UserID, time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	after := map[string]any{"currency": currency, "rate": rate}
+	if err := recordAudit(tx, guildID, actor, AuditRate, 0, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetCurrencyRate returns the most recent conversion rate for a currency, or nil if none was set
+func GetCurrencyRate(guildID string, currency string) (*CurrencyRate, error) {
+	rate := &CurrencyRate{}
+	err := db.QueryRow(`
+		SELECT id, guild_id, currency, rate, set_by, created_at
+		FROM currency_rates
+		WHERE guild_id = ? AND currency = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, guildID, currency).Scan(
+		&rate.ID, &rate.GuildID, &rate.Currency, &rate.Rate, &rate.SetBy, &rate.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return rate, nil
+}
+
+// ListCurrencyRates returns the most recent conversion rate of every currency set for a guild
+func ListCurrencyRates(guildID string) ([]CurrencyRate, error) {
+	rows, err := db.Query(`
+		SELECT r.id, r.guild_id, r.currency, r.rate, r.set_by, r.created_at
+		FROM currency_rates r
+		WHERE r.guild_id = ? AND r.id = (
+			SELECT id FROM currency_rates
+			WHERE guild_id = r.guild_id AND currency = r.currency
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		)
+		ORDER BY r.currency
+	`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []CurrencyRate
+	for rows.Next() {
+		var rate CurrencyRate
+		if err := rows.Scan(
+			&rate.ID, &rate.GuildID, &rate.Currency, &rate.Rate, &rate.SetBy, &rate.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
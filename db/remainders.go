@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RemainderAward records a leftover orb from uneven division and why a participant received it
+type RemainderAward struct {
+	ID        int64
+	GuildID   string
+	ItemID    int64
+	SaleID    int64
+	UserID    string
+	Strategy  string // remainder strategy of the guild at the time of the sale
+	Seed      int64  // seed of the random draw, 0 unless the strategy was seeded random
+	Reason    string
+	Reversed  bool // the sale was reversed, so the award no longer counts
+	CreatedAt time.Time
+}
+
+// GetRemainderWins counts the leftover orbs each member of a guild received in sales that still stand
+func GetRemainderWins(guildID string) (map[string]int, error) {
+	rows, err := db.Query(`
+		SELECT user_id, COUNT(*)
+		FROM remainder_awards
+		WHERE guild_id = ? AND reversed = 0
+		GROUP BY user_id
+	`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wins := make(map[string]int)
+	for rows.Next() {
+		var userID string
+		var count int
+		if err := rows.Scan(&userID, &count); err != nil {
+			return nil, err
+		}
+		wins[userID] = count
+	}
+
+	return wins, rows.Err()
+}
+
+// GetItemRemainderAwards retrieves the leftover orbs handed out in sales of an item, oldest first
+func GetItemRemainderAwards(guildID string, itemID int64) ([]RemainderAward, error) {
+	rows, err := db.Query(`
+		SELECT id, guild_id, item_id, COALESCE(sale_id, 0), user_id, strategy, seed, reason, reversed, created_at
+		FROM remainder_awards
+		WHERE guild_id = ? AND item_id = ?
+		ORDER BY id
+	`, guildID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var awards []RemainderAward
+	for rows.Next() {
+		var award RemainderAward
+		var seed sql.NullInt64
+		if err := rows.Scan(
+			&award.ID, &award.GuildID, &award.ItemID, &award.SaleID, &award.UserID, &award.Strategy,
+			&seed, &award.Reason, &award.Reversed, &award.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		award.Seed = seed.Int64
+		awards = append(awards, award)
+	}
+
+	return awards, rows.Err()
+}
+
+// recordRemainderAwards stores the leftover orbs of a sale
+func recordRemainderAwards(tx *sql.Tx, guildID string, itemID int64, saleID int64, awards []RemainderAward, now time.Time) error {
+	for _, award := range awards {
+		var seed interface{}
+		if award.Seed != 0 {
+			seed = award.Seed
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO remainder_awards (guild_id, item_id, sale_id, user_id, strategy, seed, reason, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, guildID, itemID, saleID, award.UserID, award.Strategy, seed, award.Reason, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,318 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ShareReversed marks an unpaid share that was voided together with its compensating row
+const ShareReversed = "reversed"
+
+// Reversal actions
+const (
+	ReversalCancel = "cancel"
+	ReversalUnsell = "unsell"
+)
+
+// Reversal is an audit record of a cancelled item or a reversed sale
+type Reversal struct {
+	ID                 int64
+	GuildID            string
+	ItemID             int64
+	SaleID             int64  // sale that was reversed, 0 for cancellations
+	Action             string // "cancel", "unsell"
+	ActorID            string
+	Reason             string
+	SaleAmount         int64
+	SaleCurrency       string
+	SaleOriginalAmount int64
+	CreatedAt          time.Time
+}
+
+// CancelItem cancels an item that hasn't been sold yet
+func CancelItem(guildID string, itemID int64, reason string, actor Actor) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM items WHERE id = ? AND guild_id = ?", itemID, guildID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("item with ID %d not found", itemID)
+		}
+		return err
+	}
+
+	if status != "assigned" {
+		return fmt.Errorf("only unsold items can be cancelled")
+	}
+
+	sold, err := soldQuantity(tx, itemID)
+	if err != nil {
+		return err
+	}
+	if sold > 0 {
+		return fmt.Errorf("part of this item was already sold, reverse those sales or lower its quantity instead")
+	}
+
+	before, err := loadItemState(tx, itemID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(
+		"UPDATE items SET status = ?, updated_at = ? WHERE id = ?",
+		"cancelled", now, itemID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO item_reversals (guild_id, item_id, action, actor_id, reason, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		guildID, itemID, ReversalCancel, actor.UserID, reason, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := recordOverride(tx, guildID, actor, itemID, ""); err != nil {
+		return err
+	}
+
+	if err := auditItem(tx, guildID, actor, AuditCancel, itemID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnsellItem reverses a sale of an item and puts the quantity it sold back up for sale.
+// saleID picks the sale to reverse; 0 reverses the most recent sale that still stands.
+// Nothing is deleted: every share gets a compensating negative profit_history row.
+// Unpaid shares are voided together with their compensation, while shares that were
+// already handed over leave the participant owing the orbs back to the seller.
+func UnsellItem(guildID string, itemID int64, saleID int64, reason string, actor Actor) (*Sale, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM items WHERE id = ? AND guild_id = ?", itemID, guildID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("item with ID %d not found", itemID)
+		}
+		return nil, err
+	}
+
+	if status == "cancelled" {
+		return nil, fmt.Errorf("sales of cancelled items can't be reversed")
+	}
+
+	// Find the sale to reverse
+	query := `
+		SELECT id, guild_id, item_id, quantity, amount, currency, original_amount, seller_id, status, created_at
+		FROM sales WHERE item_id = ? AND guild_id = ? AND status = ?`
+	args := []interface{}{itemID, guildID, SaleCompleted}
+	if saleID != 0 {
+		query += " AND id = ?"
+		args = append(args, saleID)
+	}
+	query += " ORDER BY id DESC LIMIT 1"
+
+	sale := &Sale{}
+	err = tx.QueryRow(query, args...).Scan(
+		&sale.ID, &sale.GuildID, &sale.ItemID, &sale.Quantity, &sale.Amount, &sale.Currency,
+		&sale.OriginalAmount, &sale.SellerID, &sale.Status, &sale.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			if saleID != 0 {
+				return nil, fmt.Errorf("item #%d has no standing sale with ID %d", itemID, saleID)
+			}
+			return nil, fmt.Errorf("only sold items can be unsold")
+		}
+		return nil, err
+	}
+
+	// Shares reserved by an open settlement would end up paid twice
+	var reserved int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM profit_history p
+		JOIN settlements s ON p.settlement_id = s.id
+		WHERE p.sale_id = ? AND s.status = ?
+	`, sale.ID, SettlementOpen).Scan(&reserved)
+	if err != nil {
+		return nil, err
+	}
+	if reserved > 0 {
+		return nil, fmt.Errorf("shares of this sale are part of an open settlement, cancel it first")
+	}
+
+	before, err := loadItemState(tx, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the shares that haven't been reversed yet
+	rows, err := tx.Query(`
+		SELECT id, user_id, seller_id, amount, status, kind
+		FROM profit_history
+		WHERE sale_id = ? AND guild_id = ? AND reverses_id IS NULL AND status != ?
+	`, sale.ID, guildID, ShareReversed)
+	if err != nil {
+		return nil, err
+	}
+
+	type share struct {
+		id       int64
+		userID   string
+		sellerID string
+		amount   int64
+		status   string
+		kind     string
+	}
+	var shares []share
+	for rows.Next() {
+		var sh share
+		if err := rows.Scan(&sh.id, &sh.userID, &sh.sellerID, &sh.amount, &sh.status, &sh.kind); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		shares = append(shares, sh)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var lines []JournalLine
+	for _, sh := range shares {
+		compensationStatus := ShareUnpaid
+		var paidAt interface{}
+		switch {
+		case sh.status == ShareUnpaid:
+			// The orbs never moved, so the share and its compensation cancel out
+			compensationStatus = ShareReversed
+			_, err = tx.Exec("UPDATE profit_history SET status = ? WHERE id = ?", ShareReversed, sh.id)
+			if err != nil {
+				return nil, err
+			}
+		case sh.userID == sh.sellerID, sh.userID == BankUserID:
+			// The seller already holds their own share, and the bank hands its tax straight back
+			compensationStatus = SharePaid
+			paidAt = now
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO profit_history (guild_id, user_id, seller_id, item_id, sale_id, amount, status, kind, paid_at, transaction_date, reverses_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, guildID, sh.userID, sh.sellerID, itemID, sale.ID, -sh.amount, compensationStatus, sh.kind, paidAt, now, sh.id)
+		if err != nil {
+			return nil, err
+		}
+
+		// The bank hands the tax back to the seller
+		if sh.userID == BankUserID {
+			if err := recordBankTax(tx, guildID, sh.sellerID, itemID, sale.ID, -sh.amount, "sale reversed", now); err != nil {
+				return nil, err
+			}
+			if err := postJournal(tx, guildID, JournalTax, itemID, sale.ID, "sale reversed", shareLines(sh.userID, sh.sellerID, -sh.amount), now); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		lines = append(lines, shareLines(sh.userID, sh.sellerID, -sh.amount)...)
+	}
+	if err := postJournal(tx, guildID, JournalReversal, itemID, sale.ID, reason, lines, now); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE sales SET status = ?, reversed_at = ? WHERE id = ?", SaleReversed, now, sale.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Leftover orbs of a reversed sale no longer count towards round-robin
+	_, err = tx.Exec("UPDATE remainder_awards SET reversed = 1 WHERE sale_id = ?", sale.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A reversed sale says nothing about what the item is worth
+	_, err = tx.Exec("UPDATE price_observations SET reversed_at = ? WHERE sale_id = ?", now, sale.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Put the quantity back up for sale
+	if err := refreshItemSales(tx, itemID, now); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO item_reversals (guild_id, item_id, sale_id, action, actor_id, reason, sale_amount, sale_currency, sale_original_amount, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, guildID, itemID, sale.ID, ReversalUnsell, actor.UserID, reason, sale.Amount, sale.Currency, sale.OriginalAmount, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordOverride(tx, guildID, actor, itemID, fmt.Sprintf("sale #%d", sale.ID)); err != nil {
+		return nil, err
+	}
+
+	if err := auditItem(tx, guildID, actor, AuditUnsell, itemID, before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	sale.Status = SaleReversed
+	sale.ReversedAt = now
+	return sale, nil
+}
+
+// GetItemReversals retrieves the cancellations and reversed sales of an item, oldest first
+func GetItemReversals(guildID string, itemID int64) ([]Reversal, error) {
+	rows, err := db.Query(`
+		SELECT id, guild_id, item_id, COALESCE(sale_id, 0), action, actor_id, reason, sale_amount, sale_currency, sale_original_amount, created_at
+		FROM item_reversals
+		WHERE guild_id = ? AND item_id = ?
+		ORDER BY created_at
+	`, guildID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reversals []Reversal
+	for rows.Next() {
+		var reversal Reversal
+		var saleAmount, saleOriginal sql.NullInt64
+		var saleCurrency sql.NullString
+		if err := rows.Scan(
+			&reversal.ID, &reversal.GuildID, &reversal.ItemID, &reversal.SaleID, &reversal.Action, &reversal.ActorID,
+			&reversal.Reason, &saleAmount, &saleCurrency, &saleOriginal, &reversal.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		reversal.SaleAmount = saleAmount.Int64
+		reversal.SaleCurrency = saleCurrency.String
+		reversal.SaleOriginalAmount = saleOriginal.Int64
+		reversals = append(reversals, reversal)
+	}
+
+	return reversals, rows.Err()
+}
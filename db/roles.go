@@ -0,0 +1,206 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bot roles, from least to most trusted
+const (
+	RoleMember    = "member"     // tracks drops and sells the items assigned to them
+	RoleSeller    = "seller"     // once a server maps this role, only its holders can be assigned items to sell
+	RoleLootAdmin = "loot-admin" // manages the ledger and can act on any item
+)
+
+// BotRoles lists every bot role, from least to most trusted
+var BotRoles = []string{RoleMember, RoleSeller, RoleLootAdmin}
+
+// GuildRole maps a Discord role of a guild to a bot role
+type GuildRole struct {
+	GuildID string
+	RoleID  string
+	BotRole string
+	SetBy   string
+	SetAt   time.Time
+}
+
+// Override is what a loot admin did to something only its owner could otherwise act on
+type Override struct {
+	Action string // e.g. "sell", "cancel", "edit"
+	Detail string
+}
+
+// AdminOverride records a loot admin acting on something only its owner could otherwise act on
+type AdminOverride struct {
+	ID        int64
+	GuildID   string
+	UserID    string
+	Action    string // e.g. "sell", "cancel", "edit"
+	ItemID    int64  // 0 if the override wasn't on an item
+	Detail    string
+	CreatedAt time.Time
+}
+
+// RoleRank orders bot roles by trust; unknown roles rank below members
+func RoleRank(botRole string) int {
+	for rank, role := range BotRoles {
+		if role == botRole {
+			return rank
+		}
+	}
+	return -1
+}
+
+// SetGuildRole maps a Discord role to a bot role, replacing its previous mapping
+func SetGuildRole(guildID string, roleID string, botRole string, actor Actor) error {
+	if RoleRank(botRole) < 0 {
+		return fmt.Errorf("unknown bot role %q", botRole)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := guildRoleState(tx, guildID, roleID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO guild_roles (guild_id, role_id, bot_role, set_by, set_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(guild_id, role_id) DO UPDATE SET bot_role = excluded.bot_role, set_by = excluded.set_by, set_at = excluded.set_at
+	`, guildID, roleID, botRole, actor.UserID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	after := map[string]any{"role": roleID, "bot_role": botRole}
+	if err := recordAudit(tx, guildID, actor, AuditRole, 0, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveGuildRole removes the mapping of a Discord role. It returns false if the role wasn't mapped.
+func RemoveGuildRole(guildID string, roleID string, actor Actor) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	before, err := guildRoleState(tx, guildID, roleID)
+	if err != nil || before == nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM guild_roles WHERE guild_id = ? AND role_id = ?", guildID, roleID); err != nil {
+		return false, err
+	}
+
+	if err := recordAudit(tx, guildID, actor, AuditRole, 0, before, nil); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetGuildRoles retrieves the role mappings of a guild, most trusted first
+func GetGuildRoles(guildID string) ([]GuildRole, error) {
+	rows, err := db.Query(`
+		SELECT guild_id, role_id, bot_role, set_by, set_at
+		FROM guild_roles
+		WHERE guild_id = ?
+		ORDER BY set_at
+	`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []GuildRole
+	for rows.Next() {
+		var r GuildRole
+		if err := rows.Scan(&r.GuildID, &r.RoleID, &r.BotRole, &r.SetBy, &r.SetAt); err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortRoles(roles)
+	return roles, nil
+}
+
+// recordOverride stores the override an actor made, if any, in the transaction of the write it overrode.
+// Subject names what was overridden when only the write knows it, like a new sale; itemID is 0 if it wasn't an item.
+func recordOverride(tx *sql.Tx, guildID string, actor Actor, itemID int64, subject string) error {
+	if actor.Override == nil {
+		return nil
+	}
+
+	var item any
+	if itemID != 0 {
+		item = itemID
+	}
+
+	detail := strings.TrimSpace(subject + " " + actor.Override.Detail)
+	_, err := tx.Exec(
+		"INSERT INTO admin_overrides (guild_id, user_id, action, item_id, detail, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		guildID, actor.UserID, actor.Override.Action, item, detail, time.Now(),
+	)
+	return err
+}
+
+// GetItemOverrides retrieves the admin overrides on an item, oldest first
+func GetItemOverrides(guildID string, itemID int64) ([]AdminOverride, error) {
+	rows, err := db.Query(`
+		SELECT id, guild_id, user_id, action, item_id, detail, created_at
+		FROM admin_overrides
+		WHERE guild_id = ? AND item_id = ?
+		ORDER BY id
+	`, guildID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []AdminOverride
+	for rows.Next() {
+		var o AdminOverride
+		if err := rows.Scan(&o.ID, &o.GuildID, &o.UserID, &o.Action, &o.ItemID, &o.Detail, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+
+	return overrides, rows.Err()
+}
+
+// guildRoleState reads the audited mapping of a Discord role, or nil if it isn't mapped
+func guildRoleState(tx *sql.Tx, guildID string, roleID string) (map[string]any, error) {
+	var botRole string
+	err := tx.QueryRow("SELECT bot_role FROM guild_roles WHERE guild_id = ? AND role_id = ?", guildID, roleID).Scan(&botRole)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return map[string]any{"role": roleID, "bot_role": botRole}, nil
+}
+
+// sortRoles orders role mappings from the most to the least trusted bot role, keeping their order otherwise
+func sortRoles(roles []GuildRole) {
+	sort.SliceStable(roles, func(a, b int) bool {
+		return RoleRank(roles[a].BotRole) > RoleRank(roles[b].BotRole)
+	})
+}
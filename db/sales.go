@@ -0,0 +1,294 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intinig/dr-peste/currency"
+)
+
+// Sale states
+const (
+	SaleCompleted = "completed"
+	SaleReversed  = "reversed"
+)
+
+// Sale is a single trade of some or all of an item's quantity
+type Sale struct {
+	ID             int64
+	GuildID        string
+	ItemID         int64
+	Quantity       int64
+	Amount         int64  // sale amount normalized to the base currency
+	Currency       string // currency the stack was actually sold in
+	OriginalAmount int64  // sale amount in Currency
+	SellerID       string
+	Status         string // "completed", "reversed"
+	CreatedAt      time.Time
+	ReversedAt     time.Time
+}
+
+// UnitPrice returns the base currency price of a single unit of the sale
+func (s *Sale) UnitPrice() float64 {
+	if s.Quantity == 0 {
+		return 0
+	}
+	return float64(s.Amount) / float64(s.Quantity)
+}
+
+// SaleSplit is a sale together with how its proceeds are distributed
+type SaleSplit struct {
+	Quantity       int64
+	Amount         int64 // in the base currency
+	Currency       string
+	OriginalAmount int64
+	Shares         map[string]int64 // net shares of the participants, in the base currency
+	Commission     int64            // kept by the seller, taken off the top
+	Tax            int64            // paid into the guild bank, taken off the top
+	Awards         []RemainderAward // who received the leftover orbs included in Shares
+}
+
+// RecordSale records the sale of some of an item's quantity and distributes the proceeds.
+// The item stays assigned until its whole quantity has been sold.
+// The seller's own share and commission, and the bank tax, are recorded as already paid.
+func RecordSale(guildID string, itemID int64, split SaleSplit, actor Actor) (*Sale, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Check if item exists and is in assigned status
+	var status string
+	var quantity int64
+	var sellerID sql.NullString
+	err = tx.QueryRow(
+		"SELECT status, quantity, assigned_to FROM items WHERE id = ? AND guild_id = ?",
+		itemID, guildID,
+	).Scan(&status, &quantity, &sellerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("item with ID %d not found", itemID)
+		}
+		return nil, err
+	}
+
+	if status != "assigned" {
+		return nil, fmt.Errorf("item must be assigned before it can be sold")
+	}
+
+	sold, err := soldQuantity(tx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if split.Quantity <= 0 || split.Quantity > quantity-sold {
+		return nil, fmt.Errorf("can only sell between 1 and %d of this item", quantity-sold)
+	}
+
+	// Without shares the net amount would vanish from the ledger
+	if len(split.Shares) == 0 {
+		return nil, fmt.Errorf("a sale needs at least one participant to share it")
+	}
+
+	before, err := loadItemState(tx, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		INSERT INTO sales (guild_id, item_id, quantity, amount, currency, original_amount, seller_id, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, guildID, itemID, split.Quantity, split.Amount, split.Currency, split.OriginalAmount, sellerID.String, SaleCompleted, now)
+	if err != nil {
+		return nil, err
+	}
+
+	saleID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	// Record profit history; the seller already holds their own share
+	var lines []JournalLine
+	for userID, shareAmount := range split.Shares {
+		// Clean up user ID
+		cleanUserID := strings.TrimPrefix(userID, "<@")
+		cleanUserID = strings.TrimPrefix(cleanUserID, "!")
+		cleanUserID = strings.TrimSuffix(cleanUserID, ">")
+
+		shareStatus := ShareUnpaid
+		var paidAt interface{}
+		if cleanUserID == sellerID.String {
+			shareStatus = SharePaid
+			paidAt = now
+		}
+
+		if err := insertSaleShare(tx, guildID, cleanUserID, sellerID.String, itemID, saleID, shareAmount, shareStatus, KindShare, paidAt, now); err != nil {
+			return nil, err
+		}
+		lines = append(lines, shareLines(cleanUserID, sellerID.String, shareAmount)...)
+	}
+
+	// The seller keeps their commission and pays the tax into the guild bank right away
+	if split.Commission > 0 {
+		if err := insertSaleShare(tx, guildID, sellerID.String, sellerID.String, itemID, saleID, split.Commission, SharePaid, KindCommission, now, now); err != nil {
+			return nil, err
+		}
+		lines = append(lines, shareLines(sellerID.String, sellerID.String, split.Commission)...)
+	}
+	if err := postJournal(tx, guildID, JournalSale, itemID, saleID, "", lines, now); err != nil {
+		return nil, err
+	}
+	if split.Tax > 0 {
+		if err := insertSaleShare(tx, guildID, BankUserID, sellerID.String, itemID, saleID, split.Tax, SharePaid, KindTax, now, now); err != nil {
+			return nil, err
+		}
+		if err := recordBankTax(tx, guildID, sellerID.String, itemID, saleID, split.Tax, "", now); err != nil {
+			return nil, err
+		}
+		if err := postJournal(tx, guildID, JournalTax, itemID, saleID, "", shareLines(BankUserID, sellerID.String, split.Tax), now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordRemainderAwards(tx, guildID, itemID, saleID, split.Awards, now); err != nil {
+		return nil, err
+	}
+
+	if err := recordSaleObservation(tx, guildID, itemID, saleID, split.Amount, split.Quantity, sellerID.String, now); err != nil {
+		return nil, err
+	}
+
+	if err := refreshItemSales(tx, itemID, now); err != nil {
+		return nil, err
+	}
+
+	if err := recordOverride(tx, guildID, actor, itemID, fmt.Sprintf("sale #%d", saleID)); err != nil {
+		return nil, err
+	}
+
+	if err := auditItem(tx, guildID, actor, AuditSell, itemID, before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &Sale{
+		ID:             saleID,
+		GuildID:        guildID,
+		ItemID:         itemID,
+		Quantity:       split.Quantity,
+		Amount:         split.Amount,
+		Currency:       split.Currency,
+		OriginalAmount: split.OriginalAmount,
+		SellerID:       sellerID.String,
+		Status:         SaleCompleted,
+		CreatedAt:      now,
+	}, nil
+}
+
+// GetItemSales retrieves every sale of an item, including reversed ones, oldest first
+func GetItemSales(guildID string, itemID int64) ([]Sale, error) {
+	rows, err := db.Query(`
+		SELECT id, guild_id, item_id, quantity, amount, currency, original_amount, seller_id, status, created_at, reversed_at
+		FROM sales
+		WHERE guild_id = ? AND item_id = ?
+		ORDER BY id
+	`, guildID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sales []Sale
+	for rows.Next() {
+		var sale Sale
+		var reversedAt sql.NullTime
+		if err := rows.Scan(
+			&sale.ID, &sale.GuildID, &sale.ItemID, &sale.Quantity, &sale.Amount, &sale.Currency,
+			&sale.OriginalAmount, &sale.SellerID, &sale.Status, &sale.CreatedAt, &reversedAt,
+		); err != nil {
+			return nil, err
+		}
+		sale.ReversedAt = reversedAt.Time
+		sales = append(sales, sale)
+	}
+
+	return sales, rows.Err()
+}
+
+// insertSaleShare adds a profit history record for a sale
+func insertSaleShare(tx *sql.Tx, guildID string, userID string, sellerID string, itemID int64, saleID int64, amount int64, status string, kind string, paidAt interface{}, now time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO profit_history (guild_id, user_id, seller_id, item_id, sale_id, amount, status, kind, paid_at, transaction_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, guildID, userID, sellerID, itemID, saleID, amount, status, kind, paidAt, now)
+	return err
+}
+
+// soldQuantity returns how much of an item was sold in sales that still stand
+func soldQuantity(tx *sql.Tx, itemID int64) (int64, error) {
+	var sold int64
+	err := tx.QueryRow(
+		"SELECT COALESCE(SUM(quantity), 0) FROM sales WHERE item_id = ? AND status = ?",
+		itemID, SaleCompleted,
+	).Scan(&sold)
+	return sold, err
+}
+
+// refreshItemSales brings an item's status, sale totals and participant shares in line with its sales.
+// An item is distributed once its whole quantity is sold; with several sales the totals are in the base currency.
+func refreshItemSales(tx *sql.Tx, itemID int64, now time.Time) error {
+	var quantity int64
+	var status string
+	if err := tx.QueryRow("SELECT quantity, status FROM items WHERE id = ?", itemID).Scan(&quantity, &status); err != nil {
+		return err
+	}
+
+	var count, sold int64
+	var amount, originalAmount sql.NullInt64
+	var saleCurrency sql.NullString
+	err := tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(quantity), 0), SUM(amount), SUM(original_amount), MIN(currency)
+		FROM sales WHERE item_id = ? AND status = ?
+	`, itemID, SaleCompleted).Scan(&count, &sold, &amount, &originalAmount, &saleCurrency)
+	if err != nil {
+		return err
+	}
+
+	// Totals of several sales only add up in the base currency
+	if count > 1 {
+		saleCurrency = sql.NullString{String: string(currency.Base), Valid: true}
+		originalAmount = amount
+	}
+
+	if status != "cancelled" {
+		status = "assigned"
+		if count > 0 && sold >= quantity {
+			status = "distributed"
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE items SET status = ?, sale_amount = ?, sale_currency = ?, sale_original_amount = ?, updated_at = ?
+		WHERE id = ?
+	`, status, amount, saleCurrency, originalAmount, now, itemID)
+	if err != nil {
+		return err
+	}
+
+	// A participant's share of the item is the sum of their shares in every sale
+	_, err = tx.Exec(`
+		UPDATE participants SET share_amount = (
+			SELECT SUM(p.amount) FROM profit_history p
+			WHERE p.item_id = participants.item_id AND p.user_id = participants.user_id AND p.kind = ?
+		)
+		WHERE item_id = ?
+	`, KindShare, itemID)
+	return err
+}
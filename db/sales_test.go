@@ -0,0 +1,183 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openTestDB initializes a fresh database in a temporary directory for the duration of a test
+func openTestDB(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	t.Cleanup(Close)
+}
+
+// checkLedger fails the test unless the journal balances and agrees with every aggregate
+func checkLedger(t *testing.T, guildID string) {
+	t.Helper()
+	check, err := RebuildLedger(guildID)
+	if err != nil {
+		t.Fatalf("RebuildLedger() error = %v", err)
+	}
+	if !check.Consistent() || len(check.Corrected) > 0 {
+		t.Fatalf("RebuildLedger() = %+v, want a consistent ledger with nothing to correct", check)
+	}
+}
+
+// shareStatuses returns the amount and status of every share of a sale, keyed by recipient and sign
+func shareStatuses(t *testing.T, guildID string, saleID int64) map[string]string {
+	t.Helper()
+	shares, err := GetSaleShares(guildID, saleID)
+	if err != nil {
+		t.Fatalf("GetSaleShares() error = %v", err)
+	}
+	statuses := make(map[string]string, len(shares))
+	for _, share := range shares {
+		key := share.UserID
+		if share.Amount < 0 {
+			key += " reversal"
+		}
+		statuses[key] = share.Status
+	}
+	return statuses
+}
+
+func TestSaleLifecycle(t *testing.T) {
+	openTestDB(t)
+	const guild = "guild"
+	seller := Actor{UserID: "a", InteractionID: "i"}
+
+	itemID, err := AddItem(guild, "Divine Orb", 10, 0, []string{"a", "b", "c"}, nil, 0, nil, seller)
+	if err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+	if err := AssignItem(guild, itemID, "a", seller); err != nil {
+		t.Fatalf("AssignItem() error = %v", err)
+	}
+
+	// A partial sale leaves the rest of the stack for sale; the bank takes its tax off the top
+	first, err := RecordSale(guild, itemID, SaleSplit{
+		Quantity: 4, Amount: 100, Currency: "exalted", OriginalAmount: 100,
+		Shares: map[string]int64{"a": 30, "b": 30, "c": 30}, Tax: 10,
+	}, seller)
+	if err != nil {
+		t.Fatalf("RecordSale() error = %v", err)
+	}
+
+	item, err := GetItem(guild, itemID)
+	if err != nil {
+		t.Fatalf("GetItem() error = %v", err)
+	}
+	if item.Status != "assigned" || item.SoldQuantity != 4 {
+		t.Fatalf("after a partial sale the item is %s with %d sold, want assigned with 4 sold", item.Status, item.SoldQuantity)
+	}
+	if balance, _ := GetBankBalance(guild); balance != 10 {
+		t.Errorf("bank balance = %d, want the 10 tax", balance)
+	}
+
+	if _, err := RecordSale(guild, itemID, SaleSplit{Quantity: 7, Amount: 70, Shares: map[string]int64{"a": 70}}, seller); err == nil {
+		t.Error("RecordSale() of more than is left succeeded, want an error")
+	}
+	if _, err := RecordSale(guild, itemID, SaleSplit{Quantity: 1, Amount: 10}, seller); err == nil {
+		t.Error("RecordSale() without shares succeeded, want an error")
+	}
+
+	second, err := RecordSale(guild, itemID, SaleSplit{
+		Quantity: 6, Amount: 60, Currency: "exalted", OriginalAmount: 60,
+		Shares: map[string]int64{"a": 20, "b": 20, "c": 20},
+	}, seller)
+	if err != nil {
+		t.Fatalf("RecordSale() error = %v", err)
+	}
+	if item, _ = GetItem(guild, itemID); item.Status != "distributed" {
+		t.Errorf("after selling the whole stack the item is %s, want distributed", item.Status)
+	}
+	checkLedger(t, guild)
+
+	// The seller hands b their orbs, c is still waiting
+	paid, err := MarkSharesPaid(guild, itemID, "a", "b", seller)
+	if err != nil {
+		t.Fatalf("MarkSharesPaid() error = %v", err)
+	}
+	if len(paid) != 2 {
+		t.Errorf("MarkSharesPaid() paid %d shares, want b's share of both sales", len(paid))
+	}
+	checkLedger(t, guild)
+
+	// Reversing the first sale voids c's unpaid share, leaves b owing theirs back and refunds the tax
+	if _, err := UnsellItem(guild, itemID, first.ID, "wrong price", seller); err != nil {
+		t.Fatalf("UnsellItem() error = %v", err)
+	}
+
+	wantStatuses := map[string]string{
+		"a":                      SharePaid, // the seller's own share never left their hands
+		"a reversal":             SharePaid,
+		"b":                      SharePaid,
+		"b reversal":             ShareUnpaid,
+		"c":                      ShareReversed,
+		"c reversal":             ShareReversed,
+		BankUserID:               SharePaid,
+		BankUserID + " reversal": SharePaid,
+	}
+	if got := shareStatuses(t, guild, first.ID); !reflect.DeepEqual(got, wantStatuses) {
+		t.Errorf("shares after unsell = %v, want %v", got, wantStatuses)
+	}
+
+	if item, _ = GetItem(guild, itemID); item.Status != "assigned" || item.SoldQuantity != 6 {
+		t.Errorf("after unsell the item is %s with %d sold, want assigned with 6 sold", item.Status, item.SoldQuantity)
+	}
+	if balance, _ := GetBankBalance(guild); balance != 0 {
+		t.Errorf("bank balance after unsell = %d, want the tax refunded", balance)
+	}
+	if observations, _ := GetPriceObservations(guild, "Divine Orb", 10); len(observations) != 1 {
+		t.Errorf("GetPriceObservations() = %d observations, want only the standing sale's", len(observations))
+	}
+	checkLedger(t, guild)
+
+	debts, err := GetOutstandingDebts(guild)
+	if err != nil {
+		t.Fatalf("GetOutstandingDebts() error = %v", err)
+	}
+	wantDebts := []Debt{{From: "a", To: "b", Amount: -30}, {From: "a", To: "c", Amount: 20}}
+	if !reflect.DeepEqual(debts, wantDebts) {
+		t.Errorf("GetOutstandingDebts() = %v, want %v", debts, wantDebts)
+	}
+
+	// b owes a 30 and a owes c 20, so b pays c 20 and a the remaining 10
+	settlement, err := CreateSettlement(guild, seller)
+	if err != nil {
+		t.Fatalf("CreateSettlement() error = %v", err)
+	}
+	var transfers []Debt
+	for _, transfer := range settlement.Transfers {
+		transfers = append(transfers, Debt{From: transfer.FromUser, To: transfer.ToUser, Amount: transfer.Amount})
+	}
+	wantTransfers := []Debt{{From: "b", To: "c", Amount: 20}, {From: "b", To: "a", Amount: 10}}
+	if !reflect.DeepEqual(transfers, wantTransfers) {
+		t.Errorf("CreateSettlement() transfers = %v, want %v", transfers, wantTransfers)
+	}
+
+	// Shares reserved by the open plan can't be reversed underneath it
+	_, err = UnsellItem(guild, itemID, second.ID, "", seller)
+	if err == nil || !strings.Contains(err.Error(), "open settlement") {
+		t.Errorf("UnsellItem() during an open settlement error = %v, want it blocked", err)
+	}
+
+	for _, transfer := range settlement.Transfers {
+		settlement, err = ConfirmSettlementTransfer(guild, transfer.ID, Actor{UserID: transfer.FromUser})
+		if err != nil {
+			t.Fatalf("ConfirmSettlementTransfer() error = %v", err)
+		}
+	}
+	if settlement.Status != SettlementCompleted {
+		t.Errorf("settlement is %s after confirming every transfer, want completed", settlement.Status)
+	}
+	if debts, _ := GetOutstandingDebts(guild); len(debts) != 0 {
+		t.Errorf("GetOutstandingDebts() after settling = %v, want none", debts)
+	}
+	checkLedger(t, guild)
+}
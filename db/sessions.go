@@ -0,0 +1,225 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Session states
+const (
+	SessionActive = "active"
+	SessionEnded  = "ended"
+)
+
+// Session is a farming run of a party in a channel; drops added in the channel while it is active belong to it
+type Session struct {
+	ID           int64
+	GuildID      string
+	ChannelID    string
+	Name         string
+	CreatedBy    string
+	Status       string // "active", "ended"
+	Participants []string
+	StartedAt    time.Time
+	EndedAt      time.Time
+}
+
+// SessionSummary totals the drops of a session
+type SessionSummary struct {
+	ItemCount      int64 // items that weren't cancelled
+	UnsoldCount    int64 // items with stacks left to sell
+	EstimatedTotal int64 // estimated value of the items when they were added
+	RealizedTotal  int64 // sales that still stand
+}
+
+// StartSession opens a farming session in a channel. A channel can only have one active session.
+func StartSession(guildID string, channelID string, name string, participants []string, actor Actor) (*Session, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("session name can't be empty")
+	}
+	if len(participants) == 0 {
+		return nil, fmt.Errorf("a session needs at least one participant")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var activeName string
+	err = tx.QueryRow(
+		"SELECT name FROM sessions WHERE guild_id = ? AND channel_id = ? AND status = ?",
+		guildID, channelID, SessionActive,
+	).Scan(&activeName)
+	if err == nil {
+		return nil, fmt.Errorf("session %q is still running in this channel", activeName)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(
+		"INSERT INTO sessions (guild_id, channel_id, name, created_by, status, started_at) VALUES (?, ?, ?, ?, ?, ?)",
+		guildID, channelID, name, actor.UserID, SessionActive, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, userID := range participants {
+		if _, err := tx.Exec("INSERT INTO session_participants (session_id, user_id) VALUES (?, ?)", sessionID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	after := map[string]any{"session": sessionID, "name": name, "channel": channelID, "status": SessionActive, "participants": participants}
+	if err := recordAudit(tx, guildID, actor, AuditSessionStart, 0, nil, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		ID:           sessionID,
+		GuildID:      guildID,
+		ChannelID:    channelID,
+		Name:         name,
+		CreatedBy:    actor.UserID,
+		Status:       SessionActive,
+		Participants: participants,
+		StartedAt:    now,
+	}, nil
+}
+
+// GetActiveSession retrieves the session running in a channel, or nil if there is none
+func GetActiveSession(guildID string, channelID string) (*Session, error) {
+	var sessionID int64
+	err := db.QueryRow(
+		"SELECT id FROM sessions WHERE guild_id = ? AND channel_id = ? AND status = ?",
+		guildID, channelID, SessionActive,
+	).Scan(&sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return GetSession(guildID, sessionID)
+}
+
+// GetSession retrieves a session with its participants
+func GetSession(guildID string, sessionID int64) (*Session, error) {
+	session := &Session{}
+	var endedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT id, guild_id, channel_id, name, created_by, status, started_at, ended_at
+		FROM sessions WHERE id = ? AND guild_id = ?
+	`, sessionID, guildID).Scan(
+		&session.ID, &session.GuildID, &session.ChannelID, &session.Name, &session.CreatedBy, &session.Status, &session.StartedAt, &endedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session #%d not found", sessionID)
+		}
+		return nil, err
+	}
+	session.EndedAt = endedAt.Time
+
+	rows, err := db.Query("SELECT user_id FROM session_participants WHERE session_id = ? ORDER BY rowid", sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		session.Participants = append(session.Participants, userID)
+	}
+
+	return session, rows.Err()
+}
+
+// EndSession ends the session running in a channel and returns it
+func EndSession(guildID string, channelID string, actor Actor) (*Session, error) {
+	session, err := GetActiveSession(guildID, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("no session is running in this channel")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(
+		"UPDATE sessions SET status = ?, ended_at = ? WHERE id = ? AND status = ?",
+		SessionEnded, now, session.ID, SessionActive,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Someone else may have ended it in the meantime
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 0 {
+		return nil, fmt.Errorf("session %q was already ended", session.Name)
+	}
+
+	before := map[string]any{"session": session.ID, "name": session.Name, "status": SessionActive}
+	after := map[string]any{"session": session.ID, "name": session.Name, "status": SessionEnded}
+	if err := recordOverride(tx, guildID, actor, 0, fmt.Sprintf("session #%d", session.ID)); err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(tx, guildID, actor, AuditSessionEnd, 0, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	session.Status = SessionEnded
+	session.EndedAt = now
+	return session, nil
+}
+
+// GetSessionSummary totals the items of a session that weren't cancelled
+func GetSessionSummary(guildID string, sessionID int64) (*SessionSummary, error) {
+	summary := &SessionSummary{}
+	err := db.QueryRow(`
+		SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN status = 'assigned' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(estimated_value), 0),
+			COALESCE(SUM((SELECT COALESCE(SUM(s.amount), 0) FROM sales s WHERE s.item_id = items.id AND s.status = 'completed')), 0)
+		FROM items
+		WHERE guild_id = ? AND session_id = ? AND status != 'cancelled'
+	`, guildID, sessionID).Scan(&summary.ItemCount, &summary.UnsoldCount, &summary.EstimatedTotal, &summary.RealizedTotal)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,416 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/intinig/dr-peste/settle"
+)
+
+// Settlement states
+const (
+	SettlementOpen      = "open"
+	SettlementCompleted = "completed"
+	SettlementCancelled = "cancelled"
+)
+
+// Settlement is a plan of transfers that clears a set of unpaid shares
+type Settlement struct {
+	ID          int64
+	GuildID     string
+	CreatedBy   string
+	Status      string // "open", "completed", "cancelled"
+	ShareCount  int64
+	CreatedAt   time.Time
+	CompletedAt time.Time
+	Transfers   []SettlementTransfer
+}
+
+// SettlementTransfer is a single payment of a settlement plan
+type SettlementTransfer struct {
+	ID           int64
+	SettlementID int64
+	FromUser     string
+	ToUser       string
+	Amount       int64
+	ConfirmedAt  time.Time
+}
+
+// Confirmed reports whether the payer confirmed sending the transfer
+func (t SettlementTransfer) Confirmed() bool {
+	return !t.ConfirmedAt.IsZero()
+}
+
+// CreateSettlement plans the fewest transfers that clear every unpaid share of a guild.
+// The shares are reserved for the settlement so they can't be paid out twice.
+// It returns nil if there is nothing to settle.
+func CreateSettlement(guildID string, actor Actor) (*Settlement, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Only one plan can be open at a time, otherwise transfers would overlap
+	var openID int64
+	err = tx.QueryRow(
+		"SELECT id FROM settlements WHERE guild_id = ? AND status = ?",
+		guildID, SettlementOpen,
+	).Scan(&openID)
+	if err == nil {
+		return nil, fmt.Errorf("settlement #%d is still open", openID)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// Net the unpaid shares into a balance per member
+	rows, err := tx.Query(`
+		SELECT seller_id, user_id, amount
+		FROM profit_history
+		WHERE guild_id = ? AND status = ? AND seller_id != user_id AND settlement_id IS NULL
+	`, guildID, ShareUnpaid)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]int64)
+	var shareCount int64
+	for rows.Next() {
+		var sellerID, userID string
+		var amount int64
+		if err := rows.Scan(&sellerID, &userID, &amount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		balances[sellerID] -= amount
+		balances[userID] += amount
+		shareCount++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if shareCount == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(
+		"INSERT INTO settlements (guild_id, created_by, status, share_count, created_at) VALUES (?, ?, ?, ?, ?)",
+		guildID, actor.UserID, SettlementOpen, shareCount, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	settlementID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	settlement := &Settlement{
+		ID:         settlementID,
+		GuildID:    guildID,
+		CreatedBy:  actor.UserID,
+		Status:     SettlementOpen,
+		ShareCount: shareCount,
+		CreatedAt:  now,
+	}
+
+	for _, transfer := range settle.MinCashFlow(balances) {
+		result, err := tx.Exec(
+			"INSERT INTO settlement_transfers (settlement_id, from_user, to_user, amount) VALUES (?, ?, ?, ?)",
+			settlementID, transfer.From, transfer.To, transfer.Amount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		transferID, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+
+		settlement.Transfers = append(settlement.Transfers, SettlementTransfer{
+			ID:           transferID,
+			SettlementID: settlementID,
+			FromUser:     transfer.From,
+			ToUser:       transfer.To,
+			Amount:       transfer.Amount,
+		})
+	}
+
+	// Reserve the shares covered by this plan
+	_, err = tx.Exec(`
+		UPDATE profit_history SET settlement_id = ?
+		WHERE guild_id = ? AND status = ? AND seller_id != user_id AND settlement_id IS NULL
+	`, settlementID, guildID, ShareUnpaid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Balances that net to zero need no transfers at all
+	if len(settlement.Transfers) == 0 {
+		if err := completeSettlement(tx, guildID, settlementID, now); err != nil {
+			return nil, err
+		}
+		settlement.Status = SettlementCompleted
+		settlement.CompletedAt = now
+	}
+
+	after := map[string]any{"settlement": settlementID, "status": settlement.Status, "shares": shareCount, "transfers": len(settlement.Transfers)}
+	if err := recordAudit(tx, guildID, actor, AuditSettlementCreate, 0, nil, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return settlement, nil
+}
+
+// GetOpenSettlement returns the guild's open settlement, or nil if there is none
+func GetOpenSettlement(guildID string) (*Settlement, error) {
+	var settlementID int64
+	err := db.QueryRow(
+		"SELECT id FROM settlements WHERE guild_id = ? AND status = ?",
+		guildID, SettlementOpen,
+	).Scan(&settlementID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return GetSettlement(guildID, settlementID)
+}
+
+// GetSettlement retrieves a settlement and its transfers
+func GetSettlement(guildID string, settlementID int64) (*Settlement, error) {
+	settlement := &Settlement{}
+	var completedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT id, guild_id, created_by, status, share_count, created_at, completed_at
+		FROM settlements WHERE id = ? AND guild_id = ?
+	`, settlementID, guildID).Scan(
+		&settlement.ID, &settlement.GuildID, &settlement.CreatedBy, &settlement.Status,
+		&settlement.ShareCount, &settlement.CreatedAt, &completedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("settlement with ID %d not found", settlementID)
+		}
+		return nil, err
+	}
+	settlement.CompletedAt = completedAt.Time
+
+	rows, err := db.Query(`
+		SELECT id, settlement_id, from_user, to_user, amount, confirmed_at
+		FROM settlement_transfers WHERE settlement_id = ?
+		ORDER BY amount DESC, id
+	`, settlementID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transfer SettlementTransfer
+		var confirmedAt sql.NullTime
+		if err := rows.Scan(
+			&transfer.ID, &transfer.SettlementID, &transfer.FromUser, &transfer.ToUser,
+			&transfer.Amount, &confirmedAt,
+		); err != nil {
+			return nil, err
+		}
+		transfer.ConfirmedAt = confirmedAt.Time
+		settlement.Transfers = append(settlement.Transfers, transfer)
+	}
+
+	return settlement, rows.Err()
+}
+
+// ConfirmSettlementTransfer records that the payer sent a transfer.
+// Once every transfer of the plan is confirmed, the reserved shares are marked as paid.
+// It returns the updated settlement.
+func ConfirmSettlementTransfer(guildID string, transferID int64, actor Actor) (*Settlement, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var settlementID int64
+	var fromUser, toUser, status string
+	var amount int64
+	var confirmedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT t.settlement_id, t.from_user, t.to_user, t.amount, t.confirmed_at, s.status
+		FROM settlement_transfers t
+		JOIN settlements s ON t.settlement_id = s.id
+		WHERE t.id = ? AND s.guild_id = ?
+	`, transferID, guildID).Scan(&settlementID, &fromUser, &toUser, &amount, &confirmedAt, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("transfer with ID %d not found", transferID)
+		}
+		return nil, err
+	}
+
+	if status != SettlementOpen {
+		return nil, fmt.Errorf("this settlement is %s", status)
+	}
+	if fromUser != actor.UserID {
+		return nil, fmt.Errorf("only the member sending the orbs can confirm this transfer")
+	}
+	if confirmedAt.Valid {
+		return nil, fmt.Errorf("this transfer was already confirmed")
+	}
+
+	now := time.Now()
+	_, err = tx.Exec("UPDATE settlement_transfers SET confirmed_at = ? WHERE id = ?", now, transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending int
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM settlement_transfers WHERE settlement_id = ? AND confirmed_at IS NULL",
+		settlementID,
+	).Scan(&pending)
+	if err != nil {
+		return nil, err
+	}
+
+	if pending == 0 {
+		if err := completeSettlement(tx, guildID, settlementID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	transfer := map[string]any{"settlement": settlementID, "transfer": transferID, "from": fromUser, "to": toUser, "amount": amount}
+	before := map[string]any{"transfer": transfer, "confirmed": false, "settlement_status": SettlementOpen}
+	after := map[string]any{"transfer": transfer, "confirmed": true, "settlement_status": SettlementOpen}
+	if pending == 0 {
+		after["settlement_status"] = SettlementCompleted
+	}
+	if err := recordAudit(tx, guildID, actor, AuditSettlementConfirm, 0, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return GetSettlement(guildID, settlementID)
+}
+
+// CancelSettlement abandons an open settlement and releases its shares.
+// Settlements with confirmed transfers can't be cancelled because orbs already moved.
+func CancelSettlement(guildID string, settlementID int64, actor Actor) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(
+		"SELECT status FROM settlements WHERE id = ? AND guild_id = ?",
+		settlementID, guildID,
+	).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("settlement with ID %d not found", settlementID)
+		}
+		return err
+	}
+
+	if status != SettlementOpen {
+		return fmt.Errorf("settlement #%d is already %s", settlementID, status)
+	}
+
+	var confirmed int
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM settlement_transfers WHERE settlement_id = ? AND confirmed_at IS NOT NULL",
+		settlementID,
+	).Scan(&confirmed)
+	if err != nil {
+		return err
+	}
+
+	if confirmed > 0 {
+		return fmt.Errorf("settlement #%d already has %d confirmed transfers", settlementID, confirmed)
+	}
+
+	_, err = tx.Exec("UPDATE profit_history SET settlement_id = NULL WHERE settlement_id = ?", settlementID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE settlements SET status = ? WHERE id = ?", SettlementCancelled, settlementID)
+	if err != nil {
+		return err
+	}
+
+	before := map[string]any{"settlement": settlementID, "status": SettlementOpen}
+	after := map[string]any{"settlement": settlementID, "status": SettlementCancelled}
+	if err := recordOverride(tx, guildID, actor, 0, fmt.Sprintf("settlement #%d", settlementID)); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, guildID, actor, AuditSettlementCancel, 0, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// completeSettlement marks a settlement as completed and its reserved shares as paid
+func completeSettlement(tx *sql.Tx, guildID string, settlementID int64, now time.Time) error {
+	rows, err := tx.Query(
+		"SELECT user_id, seller_id, amount FROM profit_history WHERE settlement_id = ? AND status = ?",
+		settlementID, ShareUnpaid,
+	)
+	if err != nil {
+		return err
+	}
+
+	var lines []JournalLine
+	for rows.Next() {
+		var userID, sellerID string
+		var amount int64
+		if err := rows.Scan(&userID, &sellerID, &amount); err != nil {
+			rows.Close()
+			return err
+		}
+		lines = append(lines, payoutLines(userID, sellerID, amount)...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := postJournal(tx, guildID, JournalSettlement, 0, 0, fmt.Sprintf("settlement #%d", settlementID), lines, now); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE profit_history SET status = ?, paid_at = ? WHERE settlement_id = ? AND status = ?",
+		SharePaid, now, settlementID, ShareUnpaid,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE settlements SET status = ?, completed_at = ? WHERE id = ?",
+		SettlementCompleted, now, settlementID,
+	)
+	return err
+}
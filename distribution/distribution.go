@@ -0,0 +1,187 @@
+// Package distribution splits the proceeds of a sale between the participants of a drop.
+package distribution
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+)
+
+// Strategy decides who gets a leftover orb when participants have equal remainders
+type Strategy string
+
+// Remainder strategies
+const (
+	SellerFirst  Strategy = "seller_first"  // the seller, then participants in the order they were added
+	RoundRobin   Strategy = "round_robin"   // participants with the fewest past leftover orbs first
+	SeededRandom Strategy = "seeded_random" // a random draw that can be replayed from its seed
+)
+
+// Strategies lists every remainder strategy
+var Strategies = []Strategy{SellerFirst, RoundRobin, SeededRandom}
+
+// ParseStrategy returns the strategy with the given name
+func ParseStrategy(name string) (Strategy, error) {
+	for _, strategy := range Strategies {
+		if string(strategy) == name {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown remainder strategy: %s", name)
+}
+
+// Name returns a human readable name of the strategy
+func (s Strategy) Name() string {
+	switch s {
+	case RoundRobin:
+		return "Round-robin"
+	case SeededRandom:
+		return "Seeded random"
+	default:
+		return "Seller first"
+	}
+}
+
+// Order returns the participants in the order a strategy breaks ties in.
+// participants must be in the order they were added; wins counts each member's past
+// leftover orbs and is only used by RoundRobin, seed is only used by SeededRandom.
+func (s Strategy) Order(participants []string, seller string, wins map[string]int, seed int64) []string {
+	order := append([]string(nil), participants...)
+	switch s {
+	case RoundRobin:
+		sort.SliceStable(order, func(a, b int) bool {
+			return wins[order[a]] < wins[order[b]]
+		})
+	case SeededRandom:
+		random := rand.New(rand.NewSource(seed))
+		random.Shuffle(len(order), func(a, b int) {
+			order[a], order[b] = order[b], order[a]
+		})
+	default:
+		sort.SliceStable(order, func(a, b int) bool {
+			return order[a] == seller && order[b] != seller
+		})
+	}
+	return order
+}
+
+// Reason explains why a participant received a leftover orb
+func (s Strategy) Reason(award Award, seller string, wins map[string]int, seed int64) string {
+	if award.ByRemainder {
+		return "largest remainder"
+	}
+
+	switch s {
+	case RoundRobin:
+		return fmt.Sprintf("tie, fewest past leftover orbs (%d)", wins[award.Member])
+	case SeededRandom:
+		return fmt.Sprintf("tie, random draw with seed %d", seed)
+	default:
+		if award.Member == seller {
+			return "tie, seller first"
+		}
+		return "tie, order participants were added"
+	}
+}
+
+// Award is a leftover orb handed to a participant
+type Award struct {
+	Member string
+	// ByRemainder is true when the participant's remainder was strictly larger than
+	// that of everyone who missed out, so no tie-break was needed
+	ByRemainder bool
+}
+
+// Result is the outcome of splitting a sale
+type Result struct {
+	// Shares maps every participant to the orbs they receive
+	Shares map[string]int64
+	// Awards lists the leftover orbs, in award order
+	Awards []Award
+}
+
+// Proportional splits total between participants in proportion to their weights.
+// Every participant first gets the whole part of their exact quota; the orbs that are
+// left over are handed out one at a time by largest remainder (Hamilton's method), so no
+// participant ever ends up more than one orb away from their exact quota.
+// Participants with equal remainders are ordered by priority, and those missing from
+// priority by member ID, so the same input always gives the same split.
+// Weights must be positive.
+func Proportional(total int64, weights map[string]float64, priority []string) Result {
+	result := Result{Shares: make(map[string]int64, len(weights))}
+	if len(weights) == 0 {
+		return result
+	}
+
+	// Work with exact fractions so the remainders compare reliably
+	totalWeight := new(big.Rat)
+	exact := make(map[string]*big.Rat, len(weights))
+	for member, weight := range weights {
+		w := new(big.Rat).SetFloat64(weight)
+		exact[member] = w
+		totalWeight.Add(totalWeight, w)
+	}
+
+	type quota struct {
+		member    string
+		remainder *big.Rat
+		rank      int
+	}
+
+	rank := make(map[string]int, len(priority))
+	for idx, member := range priority {
+		if _, ok := rank[member]; !ok {
+			rank[member] = idx
+		}
+	}
+
+	var quotas []quota
+	allocated := int64(0)
+	for member, w := range exact {
+		// quota = total * weight / totalWeight
+		q := new(big.Rat).Mul(new(big.Rat).SetInt64(total), w)
+		q.Quo(q, totalWeight)
+
+		whole := new(big.Int).Quo(q.Num(), q.Denom())
+		share := whole.Int64()
+		result.Shares[member] = share
+		allocated += share
+
+		memberRank, ok := rank[member]
+		if !ok {
+			memberRank = len(priority)
+		}
+		quotas = append(quotas, quota{
+			member:    member,
+			remainder: new(big.Rat).Sub(q, new(big.Rat).SetInt(whole)),
+			rank:      memberRank,
+		})
+	}
+
+	sort.Slice(quotas, func(a, b int) bool {
+		if c := quotas[a].remainder.Cmp(quotas[b].remainder); c != 0 {
+			return c > 0
+		}
+		if quotas[a].rank != quotas[b].rank {
+			return quotas[a].rank < quotas[b].rank
+		}
+		return quotas[a].member < quotas[b].member
+	})
+
+	// Hand out the leftover orbs, at most one per participant
+	leftover := total - allocated
+	for idx := int64(0); idx < leftover && idx < int64(len(quotas)); idx++ {
+		member := quotas[idx].member
+		result.Shares[member]++
+
+		// The first participant left out decides whether the tie-break mattered
+		byRemainder := true
+		if leftover < int64(len(quotas)) {
+			byRemainder = quotas[idx].remainder.Cmp(quotas[leftover].remainder) > 0
+		}
+		result.Awards = append(result.Awards, Award{Member: member, ByRemainder: byRemainder})
+	}
+
+	return result
+}
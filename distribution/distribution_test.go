@@ -0,0 +1,131 @@
+package distribution
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProportional(t *testing.T) {
+	tests := []struct {
+		name       string
+		total      int64
+		weights    map[string]float64
+		priority   []string
+		wantShares map[string]int64
+		wantAwards []Award
+	}{
+		{
+			name:       "even split",
+			total:      90,
+			weights:    map[string]float64{"a": 1, "b": 1, "c": 1},
+			wantShares: map[string]int64{"a": 30, "b": 30, "c": 30},
+		},
+		{
+			name:       "weighted split",
+			total:      100,
+			weights:    map[string]float64{"a": 1, "b": 3},
+			wantShares: map[string]int64{"a": 25, "b": 75},
+		},
+		{
+			name:       "tied remainders follow priority",
+			total:      10,
+			weights:    map[string]float64{"a": 1, "b": 1, "c": 1},
+			priority:   []string{"c", "a", "b"},
+			wantShares: map[string]int64{"a": 3, "b": 3, "c": 4},
+			wantAwards: []Award{{Member: "c"}},
+		},
+		{
+			name:       "tied remainders without priority follow member ID",
+			total:      11,
+			weights:    map[string]float64{"a": 1, "b": 1, "c": 1},
+			wantShares: map[string]int64{"a": 4, "b": 4, "c": 3},
+			wantAwards: []Award{{Member: "a"}, {Member: "b"}},
+		},
+		{
+			name:       "largest remainder wins over priority",
+			total:      10,
+			weights:    map[string]float64{"a": 1, "b": 2},
+			priority:   []string{"a", "b"},
+			wantShares: map[string]int64{"a": 3, "b": 7},
+			wantAwards: []Award{{Member: "b", ByRemainder: true}},
+		},
+		{
+			name:       "fractional weights",
+			total:      7,
+			weights:    map[string]float64{"a": 0.5, "b": 1.5},
+			wantShares: map[string]int64{"a": 2, "b": 5},
+			wantAwards: []Award{{Member: "a", ByRemainder: true}},
+		},
+		{
+			name:       "fewer orbs than participants",
+			total:      2,
+			weights:    map[string]float64{"a": 1, "b": 1, "c": 1},
+			priority:   []string{"b", "c", "a"},
+			wantShares: map[string]int64{"a": 0, "b": 1, "c": 1},
+			wantAwards: []Award{{Member: "b"}, {Member: "c"}},
+		},
+		{
+			name:       "nothing to split",
+			total:      0,
+			weights:    map[string]float64{"a": 1, "b": 1},
+			wantShares: map[string]int64{"a": 0, "b": 0},
+		},
+		{
+			name:       "no participants",
+			total:      10,
+			weights:    map[string]float64{},
+			wantShares: map[string]int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Proportional(tt.total, tt.weights, tt.priority)
+			if !reflect.DeepEqual(got.Shares, tt.wantShares) {
+				t.Errorf("Proportional() shares = %v, want %v", got.Shares, tt.wantShares)
+			}
+			if !reflect.DeepEqual(got.Awards, tt.wantAwards) {
+				t.Errorf("Proportional() awards = %v, want %v", got.Awards, tt.wantAwards)
+			}
+
+			if len(tt.weights) > 0 {
+				var sum int64
+				for _, share := range got.Shares {
+					sum += share
+				}
+				if sum != tt.total {
+					t.Errorf("Proportional() shares sum to %d, want %d", sum, tt.total)
+				}
+			}
+		})
+	}
+}
+
+func TestStrategyOrder(t *testing.T) {
+	participants := []string{"a", "b", "c"}
+	wins := map[string]int{"a": 2, "b": 0, "c": 1}
+
+	tests := []struct {
+		strategy Strategy
+		want     []string
+	}{
+		{SellerFirst, []string{"c", "a", "b"}},
+		{RoundRobin, []string{"b", "c", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			got := tt.strategy.Order(participants, "c", wins, 0)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Order() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A seeded draw can be replayed
+	first := SeededRandom.Order(participants, "c", nil, 42)
+	second := SeededRandom.Order(participants, "c", nil, 42)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Order() with the same seed = %v and %v, want the same order", first, second)
+	}
+}
@@ -1,25 +1,60 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/intinig/dr-peste/commands"
 	"github.com/intinig/dr-peste/db"
+	"github.com/intinig/dr-peste/prices"
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	migrateOnly := flag.Bool("migrate-only", false, "Apply pending database migrations and exit")
+	importPrices := flag.Bool("import-prices", false, "Import the market price snapshot from PRICE_FEED and exit")
+	flag.Parse()
+
 	// Load environment variables from .env file
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("Warning: Error loading .env file:", err)
 	}
 
+	// Apply migrations without connecting to Discord (used by deployments)
+	if *migrateOnly {
+		if err := db.Initialize(); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+		defer db.Close()
+
+		version, err := db.SchemaVersion()
+		if err != nil {
+			log.Fatal("Failed to read schema version:", err)
+		}
+		log.Printf("Database is at schema version %d", version)
+		return
+	}
+
+	// Import market prices without connecting to Discord (used by scheduled jobs)
+	if *importPrices {
+		if err := db.Initialize(); err != nil {
+			log.Fatal("Failed to initialize database:", err)
+		}
+		defer db.Close()
+
+		if err := importMarketPrices(os.Getenv("PRICE_FEED")); err != nil {
+			log.Fatal("Failed to import market prices:", err)
+		}
+		return
+	}
+
 	// Get Discord token from environment variables
 	token := os.Getenv("DISCORD_TOKEN")
 	if token == "" {
@@ -41,12 +76,33 @@ func main() {
 	}
 	defer db.Close()
 
+	// Rows created before multi-guild support belong to the configured guild
+	if guildID != "" {
+		claimed, err := db.ClaimUnscopedData(guildID)
+		if err != nil {
+			log.Fatal("Failed to assign existing items to guild:", err)
+		}
+		if claimed > 0 {
+			log.Printf("Assigned %d existing items to guild %s", claimed, guildID)
+		}
+	}
+
+	// Refresh the market price fallback; the bot works without it
+	if feed := os.Getenv("PRICE_FEED"); feed != "" {
+		if err := importMarketPrices(feed); err != nil {
+			log.Println("Warning: Failed to import market prices:", err)
+		}
+	}
+
 	// Create a new Discord session
 	dg, err := discordgo.New("Bot " + token)
 	if err != nil {
 		log.Fatal("Error creating Discord session:", err)
 	}
 
+	// Only interactions are handled, but voice states are needed to fill in participants from a voice channel
+	dg.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildVoiceStates
+
 	// Register slash command handler
 	commands.RegisterSlashCommands(dg)
 
@@ -87,4 +143,25 @@ func main() {
 			log.Printf("Error removing '%s' command: %v", cmd.Name, err)
 		}
 	}
-} 
\ No newline at end of file
+}
+
+// importMarketPrices imports a market snapshot from a comma separated list of overview paths or URLs
+func importMarketPrices(feed string) error {
+	sources := prices.ParseSources(feed)
+	if len(sources) == 0 {
+		return fmt.Errorf("no price feed configured, set the PRICE_FEED environment variable")
+	}
+
+	marketPrices, err := prices.LoadMarket(sources)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := db.SaveMarketSnapshot(strings.Join(sources, ","), marketPrices)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d market prices from %s", snapshot.ItemCount, snapshot.Source)
+	return nil
+}
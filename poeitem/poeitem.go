@@ -0,0 +1,177 @@
+// Package poeitem parses the text Path of Exile copies to the clipboard when pressing Ctrl+C on an item.
+package poeitem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// separator divides the sections of an item's clipboard text
+const separator = "--------"
+
+// Rarities that change how an item's name lines are read
+const (
+	Magic  = "Magic"
+	Rare   = "Rare"
+	Unique = "Unique"
+)
+
+// Item is an item as described by its clipboard text
+type Item struct {
+	Class        string // e.g. "Stackable Currency", "Belts"
+	Rarity       string // e.g. "Unique", "Rare", "Currency"
+	Name         string // unique or rare name, or the full name of other items
+	BaseType     string // e.g. "Heavy Belt"; empty when it can't be told from the name
+	ItemLevel    int
+	StackSize    int
+	MaxStackSize int
+	Mods         []string
+	Corrupted    bool
+	Unidentified bool
+}
+
+// Parse reads an item from its clipboard text
+func Parse(text string) (*Item, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var sections [][]string
+	for _, chunk := range strings.Split(text, separator) {
+		var lines []string
+		for _, line := range strings.Split(chunk, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			sections = append(sections, lines)
+		}
+	}
+
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("the item text is empty")
+	}
+
+	item := &Item{}
+	if err := item.parseHeader(sections[0]); err != nil {
+		return nil, err
+	}
+
+	// Mods follow the item level; everything before it is properties and requirements
+	var modSections [][]string
+	afterItemLevel := false
+	for _, section := range sections[1:] {
+		var rest []string
+		for _, line := range section {
+			switch {
+			case strings.HasPrefix(line, "Stack Size:"):
+				item.StackSize, item.MaxStackSize = parseStackSize(strings.TrimPrefix(line, "Stack Size:"))
+			case strings.HasPrefix(line, "Item Level:"):
+				item.ItemLevel, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Item Level:")))
+				afterItemLevel = true
+			case line == "Corrupted":
+				item.Corrupted = true
+			case line == "Unidentified":
+				item.Unidentified = true
+			case line == "Mirrored", strings.HasPrefix(line, "Note:"):
+			default:
+				rest = append(rest, line)
+			}
+		}
+
+		if afterItemLevel && len(rest) > 0 && !strings.HasPrefix(rest[0], "Requires") {
+			modSections = append(modSections, rest)
+		}
+	}
+
+	// The last section of a unique is usually its flavour text, which has no numbers
+	if item.Rarity == Unique && len(modSections) > 1 && !hasDigits(modSections[len(modSections)-1]) {
+		modSections = modSections[:len(modSections)-1]
+	}
+	for _, section := range modSections {
+		item.Mods = append(item.Mods, section...)
+	}
+
+	return item, nil
+}
+
+// CanonicalName returns the name the item's prices should be recorded under.
+// Rare names are random, so rares go by their base type.
+func (item *Item) CanonicalName() string {
+	switch {
+	case item.Rarity == Rare && item.BaseType != "":
+		return item.BaseType
+	case item.Name != "":
+		return item.Name
+	default:
+		return item.BaseType
+	}
+}
+
+// Quantity returns how many items the text describes, which is the stack size of stackable items
+func (item *Item) Quantity() int64 {
+	if item.StackSize > 0 {
+		return int64(item.StackSize)
+	}
+	return 1
+}
+
+// parseHeader reads the item class, rarity and name lines
+func (item *Item) parseHeader(lines []string) error {
+	var names []string
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "Item Class:"):
+			item.Class = strings.TrimSpace(strings.TrimPrefix(line, "Item Class:"))
+		case strings.HasPrefix(line, "Rarity:"):
+			item.Rarity = strings.TrimSpace(strings.TrimPrefix(line, "Rarity:"))
+		default:
+			names = append(names, line)
+		}
+	}
+
+	if item.Rarity == "" {
+		return fmt.Errorf("this doesn't look like a Path of Exile item: the Rarity line is missing")
+	}
+
+	switch len(names) {
+	case 0:
+		return fmt.Errorf("the item text has no name")
+	case 1:
+		// Unidentified rares and uniques only show their base type, and the
+		// affixes of magic items can't be told apart from the base type
+		switch item.Rarity {
+		case Rare, Unique:
+			item.BaseType = names[0]
+		case Magic:
+			item.Name = names[0]
+		default:
+			item.Name = names[0]
+			item.BaseType = names[0]
+		}
+	default:
+		item.Name = names[0]
+		item.BaseType = names[1]
+	}
+
+	return nil
+}
+
+// parseStackSize reads a stack size like "1,234/5,000"
+func parseStackSize(value string) (int, int) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", "")
+	size, maxSize, _ := strings.Cut(value, "/")
+	current, _ := strconv.Atoi(strings.TrimSpace(size))
+	maximum, _ := strconv.Atoi(strings.TrimSpace(maxSize))
+	return current, maximum
+}
+
+// hasDigits reports whether any of the lines contains a digit
+func hasDigits(lines []string) bool {
+	for _, line := range lines {
+		if strings.ContainsAny(line, "0123456789") {
+			return true
+		}
+	}
+	return false
+}
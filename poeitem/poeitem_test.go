@@ -0,0 +1,246 @@
+package poeitem
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const uniqueBelt = `Item Class: Belts
+Rarity: Unique
+Headhunter
+Heavy Belt
+--------
+Requires: Level 40
+--------
+Item Level: 82
+--------
++31 to maximum Life (implicit)
+--------
++40 to Strength
++43 to Dexterity
++51 to maximum Life
+When you Kill a Rare monster, you gain its Modifiers for 20 seconds
+--------
+"A man's soul rules from a cavern of bone, learns and
+judges through flesh-born windows. The heart is meat.
+The head is where the Man is."
+- Lavianga, Advisor to Kaom
+`
+
+const divineStack = `Item Class: Stackable Currency
+Rarity: Currency
+Divine Orb
+--------
+Stack Size: 7/20
+--------
+Randomises the numeric values of the random modifiers on an item
+--------
+Right click this item then left click on a non-unique item to apply it. Shift click to unstack.
+`
+
+const rareRing = `Item Class: Rings
+Rarity: Rare
+Storm Knot
+Sapphire Ring
+--------
+Requires: Level 46
+--------
+Item Level: 79
+--------
++22% to Cold Resistance (implicit)
+--------
++87 to maximum Life
++32% to Fire Resistance
++14% to Lightning Resistance
+--------
+Corrupted
+`
+
+const magicRing = `Item Class: Rings
+Rarity: Magic
+Sapphire Ring of the Whelpling
+--------
+Requires: Level 12
+--------
+Item Level: 34
+--------
++18% to Cold Resistance (implicit)
+--------
++21 to maximum Life
+`
+
+const unidentifiedRare = `Item Class: Body Armours
+Rarity: Rare
+Expert Keth Raiment
+--------
+Energy Shield: 196
+--------
+Item Level: 81
+--------
+Unidentified
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		want          *Item
+		wantCanonical string
+		wantQuantity  int64
+	}{
+		{
+			name: "unique",
+			text: uniqueBelt,
+			want: &Item{
+				Class:     "Belts",
+				Rarity:    Unique,
+				Name:      "Headhunter",
+				BaseType:  "Heavy Belt",
+				ItemLevel: 82,
+				Mods: []string{
+					"+31 to maximum Life (implicit)",
+					"+40 to Strength",
+					"+43 to Dexterity",
+					"+51 to maximum Life",
+					"When you Kill a Rare monster, you gain its Modifiers for 20 seconds",
+				},
+			},
+			wantCanonical: "Headhunter",
+			wantQuantity:  1,
+		},
+		{
+			name: "currency stack",
+			text: divineStack,
+			want: &Item{
+				Class:        "Stackable Currency",
+				Rarity:       "Currency",
+				Name:         "Divine Orb",
+				BaseType:     "Divine Orb",
+				StackSize:    7,
+				MaxStackSize: 20,
+			},
+			wantCanonical: "Divine Orb",
+			wantQuantity:  7,
+		},
+		{
+			name: "currency stack copied on Windows",
+			text: strings.ReplaceAll(divineStack, "\n", "\r\n"),
+			want: &Item{
+				Class:        "Stackable Currency",
+				Rarity:       "Currency",
+				Name:         "Divine Orb",
+				BaseType:     "Divine Orb",
+				StackSize:    7,
+				MaxStackSize: 20,
+			},
+			wantCanonical: "Divine Orb",
+			wantQuantity:  7,
+		},
+		{
+			name: "rare with mods",
+			text: rareRing,
+			want: &Item{
+				Class:     "Rings",
+				Rarity:    Rare,
+				Name:      "Storm Knot",
+				BaseType:  "Sapphire Ring",
+				ItemLevel: 79,
+				Mods: []string{
+					"+22% to Cold Resistance (implicit)",
+					"+87 to maximum Life",
+					"+32% to Fire Resistance",
+					"+14% to Lightning Resistance",
+				},
+				Corrupted: true,
+			},
+			wantCanonical: "Sapphire Ring",
+			wantQuantity:  1,
+		},
+		{
+			name: "magic with affixes in its name",
+			text: magicRing,
+			want: &Item{
+				Class:     "Rings",
+				Rarity:    Magic,
+				Name:      "Sapphire Ring of the Whelpling",
+				ItemLevel: 34,
+				Mods: []string{
+					"+18% to Cold Resistance (implicit)",
+					"+21 to maximum Life",
+				},
+			},
+			wantCanonical: "Sapphire Ring of the Whelpling",
+			wantQuantity:  1,
+		},
+		{
+			name: "unidentified rare",
+			text: unidentifiedRare,
+			want: &Item{
+				Class:        "Body Armours",
+				Rarity:       Rare,
+				BaseType:     "Expert Keth Raiment",
+				ItemLevel:    81,
+				Unidentified: true,
+			},
+			wantCanonical: "Expert Keth Raiment",
+			wantQuantity:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.text)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+			if canonical := got.CanonicalName(); canonical != tt.wantCanonical {
+				t.Errorf("CanonicalName() = %q, want %q", canonical, tt.wantCanonical)
+			}
+			if quantity := got.Quantity(); quantity != tt.wantQuantity {
+				t.Errorf("Quantity() = %d, want %d", quantity, tt.wantQuantity)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"empty", "  \n--------\n"},
+		{"not an item", "Divine Orb\n--------\nStack Size: 7/20"},
+		{"no name", "Item Class: Belts\nRarity: Unique\n--------\nItem Level: 82"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if item, err := Parse(tt.text); err == nil {
+				t.Errorf("Parse() = %+v, want an error", item)
+			}
+		})
+	}
+}
+
+func TestParseStackSize(t *testing.T) {
+	tests := []struct {
+		value       string
+		wantSize    int
+		wantMaxSize int
+	}{
+		{" 7/20", 7, 20},
+		{"1,234/5,000", 1234, 5000},
+		{"12", 12, 0},
+	}
+
+	for _, tt := range tests {
+		size, maxSize := parseStackSize(tt.value)
+		if size != tt.wantSize || maxSize != tt.wantMaxSize {
+			t.Errorf("parseStackSize(%q) = %d, %d, want %d, %d", tt.value, size, maxSize, tt.wantSize, tt.wantMaxSize)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package prices
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// exaltedOrb is the name of the base currency in market overviews
+const exaltedOrb = "Exalted Orb"
+
+// feedClient fetches market overviews served over HTTP
+var feedClient = &http.Client{Timeout: 30 * time.Second}
+
+// MarketPrice is what a single unit of an item goes for on the market
+type MarketPrice struct {
+	Name      string
+	UnitPrice float64 // in the base currency
+}
+
+// overviewLine is an entry of a poe.ninja currency or item overview.
+// Currency overviews name the line currencyTypeName and price it in chaosEquivalent,
+// item overviews use name and chaosValue. Newer overviews also carry exaltedValue.
+type overviewLine struct {
+	CurrencyTypeName string  `json:"currencyTypeName"`
+	ChaosEquivalent  float64 `json:"chaosEquivalent"`
+	Name             string  `json:"name"`
+	ChaosValue       float64 `json:"chaosValue"`
+	ExaltedValue     float64 `json:"exaltedValue"`
+}
+
+// overview is a poe.ninja currency or item overview
+type overview struct {
+	Lines []overviewLine `json:"lines"`
+}
+
+// LoadMarket reads market overviews from local files or http(s) URLs and returns
+// the unit price of every item in them. Overviews are read together so an item
+// overview priced in Chaos Orbs can be converted with the Exalted Orb price of a
+// currency overview listed alongside it.
+func LoadMarket(sources []string) ([]MarketPrice, error) {
+	var lines []overviewLine
+	for _, source := range sources {
+		sourceLines, err := readOverview(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", source, err)
+		}
+		lines = append(lines, sourceLines...)
+	}
+
+	return convertOverview(lines)
+}
+
+// ParseSources splits a comma separated list of market overview paths or URLs
+func ParseSources(feed string) []string {
+	var sources []string
+	for _, source := range strings.Split(feed, ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// readOverview reads the lines of a single market overview
+func readOverview(source string) ([]overviewLine, error) {
+	var body io.ReadCloser
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, err := feedClient.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		body = resp.Body
+	} else {
+		file, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		body = file
+	}
+	defer body.Close()
+
+	var o overview
+	if err := json.NewDecoder(body).Decode(&o); err != nil {
+		return nil, fmt.Errorf("invalid overview: %w", err)
+	}
+	return o.Lines, nil
+}
+
+// convertOverview prices every line in the base currency
+func convertOverview(lines []overviewLine) ([]MarketPrice, error) {
+	// Lines without an Exalted Orb value need the Chaos Orb price of an Exalted Orb
+	var exaltedChaos float64
+	for _, line := range lines {
+		if line.CurrencyTypeName == exaltedOrb && line.ChaosEquivalent > 0 {
+			exaltedChaos = line.ChaosEquivalent
+		}
+	}
+
+	seen := make(map[string]bool)
+	var prices []MarketPrice
+	for _, line := range lines {
+		name, chaos := line.Name, line.ChaosValue
+		if line.CurrencyTypeName != "" {
+			name, chaos = line.CurrencyTypeName, line.ChaosEquivalent
+		}
+		name = strings.TrimSpace(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+
+		var unitPrice float64
+		switch {
+		case name == exaltedOrb:
+			unitPrice = 1
+		case line.ExaltedValue > 0:
+			unitPrice = line.ExaltedValue
+		case chaos > 0 && exaltedChaos > 0:
+			unitPrice = chaos / exaltedChaos
+		case chaos > 0:
+			return nil, fmt.Errorf("%s is only priced in Chaos Orbs and no Exalted Orb price was found to convert it; add a currency overview to the feed", name)
+		default:
+			continue
+		}
+
+		seen[strings.ToLower(name)] = true
+		prices = append(prices, MarketPrice{Name: name, UnitPrice: unitPrice})
+	}
+
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("no prices found in the overview")
+	}
+	return prices, nil
+}
@@ -0,0 +1,128 @@
+package prices_test
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/intinig/dr-peste/db"
+	"github.com/intinig/dr-peste/prices"
+)
+
+// fixture returns the absolute path of a test overview, so it still resolves after a test changes directory
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// marketPrices indexes market prices by item name
+func marketPrices(marketPrices []prices.MarketPrice) map[string]float64 {
+	byName := make(map[string]float64, len(marketPrices))
+	for _, p := range marketPrices {
+		byName[p.Name] = p.UnitPrice
+	}
+	return byName
+}
+
+func TestLoadMarket(t *testing.T) {
+	loaded, err := prices.LoadMarket([]string{fixture(t, "currencyoverview.json"), fixture(t, "itemoverview.json")})
+	if err != nil {
+		t.Fatalf("LoadMarket() error = %v", err)
+	}
+	got := marketPrices(loaded)
+
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{"Exalted Orb", 1},
+		{"Divine Orb", 181.5 / 12.1},
+		{"Orb of Annulment", 0.5},
+		{"Mirror of Kalandra", 9000},
+		{"Headhunter", 750},  // chaosValue converted at 12.1 chaos per Exalted Orb
+		{"Tabula Rasa", 0.5}, // chaosValue converted at 12.1 chaos per Exalted Orb
+		{"Mageblood", 2950},  // exaltedValue wins over the 3000 its chaosValue converts to
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, ok := got[tt.name]
+			if !ok {
+				t.Fatalf("%s is missing from %v", tt.name, got)
+			}
+			if math.Abs(price-tt.want) > 1e-9 {
+				t.Errorf("unit price of %s = %v, want %v", tt.name, price, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMarketWithoutExaltedPrice(t *testing.T) {
+	// Headhunter and Tabula Rasa are only priced in Chaos Orbs
+	_, err := prices.LoadMarket([]string{fixture(t, "itemoverview.json")})
+	if err == nil {
+		t.Fatal("LoadMarket() without a currency overview succeeded, want an error")
+	}
+	if !strings.Contains(err.Error(), "no Exalted Orb price") {
+		t.Errorf("LoadMarket() error = %v, want it to mention the missing Exalted Orb price", err)
+	}
+}
+
+func TestPriceEstimateFallsBackToMarket(t *testing.T) {
+	currencies, items := fixture(t, "currencyoverview.json"), fixture(t, "itemoverview.json")
+	t.Chdir(t.TempDir())
+
+	if err := db.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+
+	loaded, err := prices.LoadMarket([]string{currencies, items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SaveMarketSnapshot(currencies+","+items, loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	// The guild checked the price of a Headhunter itself
+	if _, err := db.RecordPriceCheck("guild", "Headhunter", 700, db.Actor{UserID: "checker"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		item       string
+		wantOK     bool
+		wantPrice  float64
+		wantMarket bool
+	}{
+		{name: "no guild history uses the market", item: "Mageblood", wantOK: true, wantPrice: 2950, wantMarket: true},
+		{name: "guild history wins over the market", item: "Headhunter", wantOK: true, wantPrice: 700},
+		{name: "unknown everywhere", item: "Kaom's Heart"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, ok, err := db.GetPriceEstimate("guild", tt.item)
+			if err != nil {
+				t.Fatalf("GetPriceEstimate() error = %v", err)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("GetPriceEstimate() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if estimate.UnitPrice != tt.wantPrice || estimate.Market != tt.wantMarket {
+				t.Errorf("GetPriceEstimate() = %v (market %v), want %v (market %v)",
+					estimate.UnitPrice, estimate.Market, tt.wantPrice, tt.wantMarket)
+			}
+		})
+	}
+}
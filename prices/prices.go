@@ -0,0 +1,98 @@
+// Package prices estimates what an item is worth from the prices it sold for before.
+package prices
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// HalfLife is how long it takes for an observation to count half as much as a fresh one
+const HalfLife = 7 * 24 * time.Hour
+
+// Observation is the price a single unit of an item went for at some point in time
+type Observation struct {
+	UnitPrice float64 // in the base currency
+	At        time.Time
+}
+
+// Estimate is the expected price of a single unit of an item
+type Estimate struct {
+	UnitPrice float64   // in the base currency
+	Samples   int       // number of observations the estimate is based on
+	Oldest    time.Time // when the oldest observation was made
+	Newest    time.Time // when the newest observation was made
+	Market    bool      // the estimate is a market price rather than the guild's own prices
+}
+
+// Value returns the estimated value of a number of units, rounded to the nearest whole orb
+func (e Estimate) Value(quantity int64) int64 {
+	return int64(math.Round(e.UnitPrice * float64(quantity)))
+}
+
+// Weight returns how much an observation of the given age counts towards an estimate.
+// Fresh observations count 1, and the weight halves every HalfLife.
+func Weight(age time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Exp2(-float64(age) / float64(HalfLife))
+}
+
+// EstimateAt returns the time-weighted median unit price of the observations as of now.
+// A median keeps one outlier from skewing the estimate, and the decay lets the price
+// follow the market as it moves. It returns false if there are no usable observations.
+func EstimateAt(observations []Observation, now time.Time) (Estimate, bool) {
+	type weighted struct {
+		price  float64
+		weight float64
+	}
+
+	var estimate Estimate
+	var points []weighted
+	var total float64
+	for _, o := range observations {
+		if o.UnitPrice <= 0 {
+			continue
+		}
+
+		w := Weight(now.Sub(o.At))
+		points = append(points, weighted{o.UnitPrice, w})
+		total += w
+
+		if estimate.Samples == 0 || o.At.Before(estimate.Oldest) {
+			estimate.Oldest = o.At
+		}
+		if estimate.Samples == 0 || o.At.After(estimate.Newest) {
+			estimate.Newest = o.At
+		}
+		estimate.Samples++
+	}
+
+	if estimate.Samples == 0 {
+		return Estimate{}, false
+	}
+
+	sort.SliceStable(points, func(a, b int) bool {
+		return points[a].price < points[b].price
+	})
+
+	// Walk up the prices until half of the weight is behind us. If that lands exactly
+	// between two prices, like the median of an even count, take the midpoint.
+	half := total / 2
+	var cumulative float64
+	for idx, p := range points {
+		cumulative += p.weight
+		if cumulative < half-total*1e-9 {
+			continue
+		}
+
+		estimate.UnitPrice = p.price
+		if math.Abs(cumulative-half) <= total*1e-9 && idx+1 < len(points) {
+			estimate.UnitPrice = (p.price + points[idx+1].price) / 2
+		}
+		break
+	}
+
+	return estimate, true
+}
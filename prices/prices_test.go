@@ -0,0 +1,141 @@
+package prices
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEstimateAt(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	ago := func(days int) time.Time {
+		return now.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+
+	tests := []struct {
+		name         string
+		observations []Observation
+		wantOK       bool
+		wantPrice    float64
+		wantSamples  int
+		wantOldest   time.Time
+	}{
+		{
+			name: "an outlier doesn't move the median",
+			observations: []Observation{
+				{UnitPrice: 40, At: now},
+				{UnitPrice: 45, At: now},
+				{UnitPrice: 1000, At: now},
+			},
+			wantOK:      true,
+			wantPrice:   45,
+			wantSamples: 3,
+			wantOldest:  now,
+		},
+		{
+			name: "an even count takes the midpoint",
+			observations: []Observation{
+				{UnitPrice: 40, At: now},
+				{UnitPrice: 50, At: now},
+			},
+			wantOK:      true,
+			wantPrice:   45,
+			wantSamples: 2,
+			wantOldest:  now,
+		},
+		{
+			name: "fresh prices outweigh old ones",
+			observations: []Observation{
+				{UnitPrice: 10, At: ago(30)},
+				{UnitPrice: 10, At: ago(28)},
+				{UnitPrice: 20, At: ago(1)},
+			},
+			wantOK:      true,
+			wantPrice:   20,
+			wantSamples: 3,
+			wantOldest:  ago(30),
+		},
+		{
+			name: "old prices still count when they are many",
+			observations: []Observation{
+				{UnitPrice: 10, At: ago(7)},
+				{UnitPrice: 10, At: ago(7)},
+				{UnitPrice: 10, At: ago(7)},
+				{UnitPrice: 20, At: now},
+			},
+			wantOK:      true,
+			wantPrice:   10,
+			wantSamples: 4,
+			wantOldest:  ago(7),
+		},
+		{
+			name: "prices that aren't positive are skipped",
+			observations: []Observation{
+				{UnitPrice: 0, At: ago(3)},
+				{UnitPrice: -5, At: ago(2)},
+				{UnitPrice: 30, At: ago(1)},
+			},
+			wantOK:      true,
+			wantPrice:   30,
+			wantSamples: 1,
+			wantOldest:  ago(1),
+		},
+		{
+			name:         "no usable observations",
+			observations: []Observation{{UnitPrice: 0, At: now}},
+		},
+		{
+			name: "no observations",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := EstimateAt(tt.observations, now)
+			if ok != tt.wantOK {
+				t.Fatalf("EstimateAt() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got.UnitPrice != tt.wantPrice {
+				t.Errorf("EstimateAt() unit price = %v, want %v", got.UnitPrice, tt.wantPrice)
+			}
+			if got.Samples != tt.wantSamples {
+				t.Errorf("EstimateAt() samples = %d, want %d", got.Samples, tt.wantSamples)
+			}
+			if !got.Oldest.Equal(tt.wantOldest) {
+				t.Errorf("EstimateAt() oldest = %v, want %v", got.Oldest, tt.wantOldest)
+			}
+		})
+	}
+}
+
+func TestWeight(t *testing.T) {
+	tests := []struct {
+		age  time.Duration
+		want float64
+	}{
+		{-time.Hour, 1},
+		{0, 1},
+		{HalfLife, 0.5},
+		{2 * HalfLife, 0.25},
+	}
+
+	for _, tt := range tests {
+		if got := Weight(tt.age); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("Weight(%v) = %v, want %v", tt.age, got, tt.want)
+		}
+	}
+
+	if Weight(time.Hour) <= Weight(2*time.Hour) {
+		t.Error("Weight() of a fresher observation should be higher")
+	}
+}
+
+func TestEstimateValue(t *testing.T) {
+	estimate := Estimate{UnitPrice: 2.5}
+	if got := estimate.Value(3); got != 8 {
+		t.Errorf("Value(3) = %d, want 8", got)
+	}
+}
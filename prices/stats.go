@@ -0,0 +1,94 @@
+package prices
+
+import (
+	"math"
+	"sort"
+)
+
+// sparkBlocks are the bars of a sparkline, lowest first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Summary describes the spread of a set of observations, without any time decay
+type Summary struct {
+	Min    float64
+	Median float64
+	Max    float64
+	Trend  float64 // relative change from the older half of the observations to the newer half, e.g. 0.1 for +10%
+}
+
+// Summarize returns the minimum, median, maximum and trend of the observations.
+// The trend compares the medians of the older and newer halves, so it needs at least two observations.
+func Summarize(observations []Observation) Summary {
+	if len(observations) == 0 {
+		return Summary{}
+	}
+
+	chronological := make([]Observation, len(observations))
+	copy(chronological, observations)
+	sort.SliceStable(chronological, func(a, b int) bool {
+		return chronological[a].At.Before(chronological[b].At)
+	})
+
+	all := unitPrices(chronological)
+	summary := Summary{
+		Min:    all[0],
+		Median: median(all),
+		Max:    all[len(all)-1],
+	}
+
+	if len(chronological) >= 2 {
+		half := len(chronological) / 2
+		older := median(unitPrices(chronological[:half]))
+		newer := median(unitPrices(chronological[len(chronological)-half:]))
+		if older > 0 {
+			summary.Trend = (newer - older) / older
+		}
+	}
+
+	return summary
+}
+
+// Sparkline draws values as a row of bars scaled between their minimum and maximum
+func Sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	low, high := values[0], values[0]
+	for _, v := range values {
+		low = math.Min(low, v)
+		high = math.Max(high, v)
+	}
+
+	line := make([]rune, 0, len(values))
+	for _, v := range values {
+		idx := len(sparkBlocks) / 2
+		if high > low {
+			idx = int(math.Round((v - low) / (high - low) * float64(len(sparkBlocks)-1)))
+		}
+		line = append(line, sparkBlocks[idx])
+	}
+	return string(line)
+}
+
+// unitPrices returns the sorted unit prices of the observations
+func unitPrices(observations []Observation) []float64 {
+	values := make([]float64, 0, len(observations))
+	for _, o := range observations {
+		values = append(values, o.UnitPrice)
+	}
+	sort.Float64s(values)
+	return values
+}
+
+// median returns the middle of sorted values, or the midpoint of the two middle ones
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,78 @@
+// Package settle computes how members can clear their debts with as few transfers as possible.
+package settle
+
+import "sort"
+
+// Transfer is a single payment from one member to another
+type Transfer struct {
+	From   string
+	To     string
+	Amount int64
+}
+
+// MinCashFlow turns net balances into a list of transfers that clears them.
+// A positive balance means the member is owed orbs, a negative one means they owe orbs.
+// It uses the greedy min-cash-flow strategy: the largest debtor repeatedly pays the
+// largest creditor, so every step settles at least one member completely and the
+// plan never needs more than n-1 transfers. Ties are broken by member ID so the
+// plan is stable for the same input.
+func MinCashFlow(balances map[string]int64) []Transfer {
+	type balance struct {
+		member string
+		amount int64
+	}
+
+	var creditors, debtors []*balance
+	for member, amount := range balances {
+		switch {
+		case amount > 0:
+			creditors = append(creditors, &balance{member, amount})
+		case amount < 0:
+			debtors = append(debtors, &balance{member, -amount})
+		}
+	}
+
+	// largest returns the index of the biggest remaining balance
+	largest := func(balances []*balance) int {
+		best := -1
+		for idx, b := range balances {
+			if b.amount == 0 {
+				continue
+			}
+			if best == -1 || b.amount > balances[best].amount ||
+				(b.amount == balances[best].amount && b.member < balances[best].member) {
+				best = idx
+			}
+		}
+		return best
+	}
+
+	var transfers []Transfer
+	for {
+		creditor := largest(creditors)
+		debtor := largest(debtors)
+		if creditor == -1 || debtor == -1 {
+			break
+		}
+
+		amount := creditors[creditor].amount
+		if debtors[debtor].amount < amount {
+			amount = debtors[debtor].amount
+		}
+
+		transfers = append(transfers, Transfer{
+			From:   debtors[debtor].member,
+			To:     creditors[creditor].member,
+			Amount: amount,
+		})
+		creditors[creditor].amount -= amount
+		debtors[debtor].amount -= amount
+	}
+
+	// Present the biggest transfers first
+	sort.SliceStable(transfers, func(a, b int) bool {
+		return transfers[a].Amount > transfers[b].Amount
+	})
+
+	return transfers
+}
@@ -0,0 +1,85 @@
+package settle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMinCashFlow(t *testing.T) {
+	// debt is an amount one member owes another
+	type debt struct {
+		from, to string
+		amount   int64
+	}
+
+	tests := []struct {
+		name  string
+		debts []debt
+		want  []Transfer
+	}{
+		{
+			name:  "opposite debts net out",
+			debts: []debt{{"a", "b", 30}, {"b", "a", 12}},
+			want:  []Transfer{{From: "a", To: "b", Amount: 18}},
+		},
+		{
+			name:  "debts that cancel out need no transfer",
+			debts: []debt{{"a", "b", 20}, {"b", "a", 20}},
+		},
+		{
+			name:  "a chain collapses to one transfer",
+			debts: []debt{{"a", "b", 10}, {"b", "c", 10}},
+			want:  []Transfer{{From: "a", To: "c", Amount: 10}},
+		},
+		{
+			name:  "a cycle needs no transfer",
+			debts: []debt{{"a", "b", 5}, {"b", "c", 5}, {"c", "a", 5}},
+		},
+		{
+			name:  "largest debtor pays largest creditor first",
+			debts: []debt{{"a", "c", 50}, {"b", "d", 20}, {"a", "d", 10}},
+			want: []Transfer{
+				{From: "a", To: "c", Amount: 50},
+				{From: "b", To: "d", Amount: 20},
+				{From: "a", To: "d", Amount: 10},
+			},
+		},
+		{
+			name:  "ties are broken by member ID",
+			debts: []debt{{"b", "d", 10}, {"a", "c", 10}},
+			want: []Transfer{
+				{From: "a", To: "c", Amount: 10},
+				{From: "b", To: "d", Amount: 10},
+			},
+		},
+		{
+			name: "nothing owed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balances := make(map[string]int64)
+			for _, d := range tt.debts {
+				balances[d.from] -= d.amount
+				balances[d.to] += d.amount
+			}
+
+			got := MinCashFlow(balances)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MinCashFlow() = %v, want %v", got, tt.want)
+			}
+
+			// Carrying out the plan clears every balance
+			for _, transfer := range got {
+				balances[transfer.From] += transfer.Amount
+				balances[transfer.To] -= transfer.Amount
+			}
+			for member, balance := range balances {
+				if balance != 0 {
+					t.Errorf("%s is left with a balance of %d", member, balance)
+				}
+			}
+		})
+	}
+}